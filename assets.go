@@ -0,0 +1,173 @@
+package turbo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Assets is a manifest of the static assets under a directory. Each asset is
+// served at a URL that contains a hash of its contents, so that it can be
+// cached by the browser forever.
+//
+// Assets registers two template helpers when it is set on Options:
+//
+//	{{ asset_path "app.js" }}  renders the fingerprinted URL of the asset.
+//	{{ asset_tag "app.js" }}   renders a <script> or <link> tag for the asset
+//	                           that tells Turbolinks to reload the page when
+//	                           the asset changes.
+type Assets struct {
+	fsys   fs.FS
+	prefix string
+
+	mu sync.RWMutex
+
+	// paths maps the name of an asset to its fingerprinted name.
+	paths map[string]string
+
+	// files maps the fingerprinted name of an asset back to its name.
+	files map[string]string
+}
+
+// NewAssets hashes every file in the given filesystem, to be served under
+// the given URL prefix. Use os.DirFS to serve assets from a directory.
+func NewAssets(fsys fs.FS, prefix string) (*Assets, error) {
+	a := &Assets{
+		fsys:   fsys,
+		prefix: "/" + strings.Trim(prefix, "/") + "/",
+	}
+	if a.prefix == "//" {
+		a.prefix = "/"
+	}
+
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload hashes the assets again. It is called before each render when
+// Options.IsDevelopment is set, so that changes are picked up without a
+// restart.
+func (a *Assets) Reload() error {
+	paths := make(map[string]string)
+	files := make(map[string]string)
+
+	err := fs.WalkDir(a.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		buf, err := fs.ReadFile(a.fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(buf)
+
+		fingerprinted := fingerprint(name, hex.EncodeToString(sum[:8]))
+		paths[name] = fingerprinted
+		files[fingerprinted] = name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.paths = paths
+	a.files = files
+	a.mu.Unlock()
+	return nil
+}
+
+// fingerprint inserts the hash into the name of the file, before its
+// extension, ie, "css/app.css" becomes "css/app-0123456789abcdef.css".
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return name[:len(name)-len(ext)] + "-" + hash + ext
+}
+
+// Path returns the fingerprinted URL of the asset with the given name.
+func (a *Assets) Path(name string) (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fingerprinted, ok := a.paths[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return "", fmt.Errorf("turbo: asset %q not found", name)
+	}
+	return a.prefix + fingerprinted, nil
+}
+
+// Tag returns a tag that includes the asset with the given name in the page.
+// Stylesheets get a <link> tag and scripts get a <script> tag, and both are
+// tracked by Turbolinks, so that a new deploy reloads the page.
+func (a *Assets) Tag(name string) (template.HTML, error) {
+	url, err := a.Path(name)
+	if err != nil {
+		return "", err
+	}
+	url = template.HTMLEscapeString(url)
+
+	switch path.Ext(name) {
+	case ".css":
+		return template.HTML(`<link rel="stylesheet" href="` + url + `" data-turbolinks-track="reload">`), nil
+	case ".js":
+		return template.HTML(`<script src="` + url + `" data-turbolinks-track="reload"></script>`), nil
+	default:
+		return "", fmt.Errorf("turbo: no tag for asset %q", name)
+	}
+}
+
+// Funcs returns the template helpers for the assets.
+func (a *Assets) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"asset_path": a.Path,
+		"asset_tag":  a.Tag,
+	}
+}
+
+// ServeHTTP serves the assets. Fingerprinted URLs are served with far-future
+// cache headers, since their contents can never change. Assets requested by
+// their plain name are still served, but have to be revalidated each time.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, a.prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, a.prefix)
+
+	a.mu.RLock()
+	original, fingerprinted := a.files[name]
+	_, plain := a.paths[name]
+	a.mu.RUnlock()
+
+	switch {
+	case fingerprinted:
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		name = original
+	case plain:
+		w.Header().Set("Cache-Control", "no-cache")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	buf, err := fs.ReadFile(a.fsys, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(buf))
+}
@@ -0,0 +1,85 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestAssets(t *testing.T) {
+	assets, err := turbo.NewAssets(os.DirFS("fixtures/assets/static"), "/assets")
+	if err != nil {
+		t.Fatalf("unexpected error hashing assets: %v", err)
+	}
+
+	t.Run("fingerprint asset path", func(t *testing.T) {
+		p, err := assets.Path("css/app.css")
+		if err != nil {
+			t.Fatalf("unexpected error getting asset path: %v", err)
+		}
+		if !regexp.MustCompile(`^/assets/css/app-[0-9a-f]{16}\.css$`).MatchString(p) {
+			t.Fatalf("expected fingerprinted asset path but got %s", p)
+		}
+	})
+
+	t.Run("missing asset should error", func(t *testing.T) {
+		if _, err := assets.Path("missing.js"); err == nil {
+			t.Fatalf("expected error getting path of missing asset but got none")
+		}
+	})
+
+	t.Run("serve fingerprinted asset", func(t *testing.T) {
+		p, _ := assets.Path("app.js")
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		assets.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusOK, res.Code)
+		}
+		if cc := res.Header().Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+			t.Fatalf("expected far-future Cache-Control but got %s", cc)
+		}
+		if body := res.Body.String(); body != "console.log(\"turbo\");\n" {
+			t.Fatalf("expected asset contents but got %s", body)
+		}
+	})
+
+	t.Run("serve plain asset", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/assets/app.js", nil)
+		assets.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusOK, res.Code)
+		}
+		if cc := res.Header().Get("Cache-Control"); cc != "no-cache" {
+			t.Fatalf("expected no-cache Cache-Control but got %s", cc)
+		}
+	})
+
+	t.Run("render asset helpers", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Directory: "fixtures/assets/views",
+			Layout:    "layout",
+			Assets:    assets,
+		})
+		css, _ := assets.Path("css/app.css")
+		js, _ := assets.Path("app.js")
+		expected := `<link rel="stylesheet" href="` + css + `" data-turbolinks-track="reload"><script src="` + js + `"></script>`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", nil); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+}
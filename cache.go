@@ -0,0 +1,305 @@
+package turbo
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// Cache stores rendered fragments for the cache helper. Implementations must
+// be safe for concurrent use.
+type Cache interface {
+	Get(key string) (template.HTML, bool)
+	Set(key string, value template.HTML)
+	Delete(key string)
+	Clear()
+}
+
+// LRU is an in-memory Cache that evicts the least recently used fragment
+// once it holds more than its size.
+type LRU struct {
+	size int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value template.HTML
+}
+
+// NewLRU returns an LRU that holds at most size fragments.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the fragment stored under the given key.
+func (c *LRU) Get(key string) (template.HTML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// Set stores the fragment under the given key, evicting the least recently
+// used fragment if the cache is full.
+func (c *LRU) Set(key string, value template.HTML) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry).value = value
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+	for c.size > 0 && c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Delete removes the fragment stored under the given key.
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// Clear removes every fragment.
+func (c *LRU) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Len returns the number of fragments in the cache.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// The results of a cached render, as reported in RenderEvent.Cache.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// cached renders the template with the given name through the fragment
+// cache, for the cache helper, with the given function on a miss. The key is
+// derived from the name, the keys, the locale of the request, the revision
+// of the app and the contents of the template and the templates it
+// includes, so fragments are never served from an older version of either.
+func (r *Render) cached(rc *renderContext, name string, keys []interface{}, execute func() (*bytes.Buffer, error)) (template.HTML, error) {
+	if r.opt.Cache == nil {
+		buf, err := execute()
+		return template.HTML(buf.String()), err
+	}
+
+	key := r.cacheKey(rc.theme, rc.locale, name, keys...)
+	if html, ok := r.opt.Cache.Get(key); ok {
+		err := r.instrumentCache(rc, CacheHit, name, func() (int, error) {
+			return len(html), nil
+		})
+		return html, err
+	}
+
+	var html template.HTML
+	err := r.instrumentCache(rc, CacheMiss, name, func() (int, error) {
+		buf, err := execute()
+		html = template.HTML(buf.String())
+		return buf.Len(), err
+	})
+	if err != nil {
+		return html, err
+	}
+
+	r.opt.Cache.Set(key, html)
+	return html, nil
+}
+
+// instrumentCache reports a lookup in the fragment cache to the hooks.
+func (r *Render) instrumentCache(rc *renderContext, result, name string, fn func() (int, error)) error {
+	return r.instrumentEvent(rc, RenderEvent{Kind: RenderCache, Template: name, Cache: result}, fn)
+}
+
+// Invalidate removes the fragment rendered from the template with the given
+// name and keys from the cache.
+func (r *Render) Invalidate(name string, keys ...interface{}) {
+	if r.opt.Cache == nil {
+		return
+	}
+
+	// Themes that override the template render it differently, and so
+	// does every locale.
+	for _, theme := range r.themes() {
+		for _, locale := range r.locales() {
+			r.opt.Cache.Delete(r.cacheKey(theme, locale, name, keys...))
+		}
+	}
+}
+
+// locales returns every locale that a fragment can be rendered in.
+func (r *Render) locales() []string {
+	if r.opt.I18n == nil {
+		return []string{""}
+	}
+
+	locales := r.opt.I18n.Locales()
+	for _, locale := range locales {
+		if locale == r.opt.I18n.Default {
+			return locales
+		}
+	}
+	return append(locales, r.opt.I18n.Default)
+}
+
+// InvalidateAll removes every fragment rendered from the template with the
+// given name, whatever its keys. The fragments are left in the cache to be
+// evicted, but are never served again.
+func (r *Render) InvalidateAll(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.generations == nil {
+		r.generations = make(map[string]uint64)
+	}
+	r.generations[name]++
+}
+
+// cacheKey returns the key that the fragment rendered from the template with
+// the given name and keys, in the given theme and locale, is stored under.
+func (r *Render) cacheKey(theme, locale, name string, keys ...interface{}) string {
+	r.mu.RLock()
+	sum, generation := r.sets[theme].sums[name], r.generations[name]
+	r.mu.RUnlock()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%d", name, theme, locale, r.Revision(), sum, generation)
+	for _, k := range keys {
+		fmt.Fprintf(h, "\x00%T:%v", k, k)
+	}
+	return name + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// templateSum returns the hash of the source of a template.
+func templateSum(buf []byte) string {
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// includeSums returns the sums of the templates with the sums of every
+// template they include folded in, so that changing a partial changes the
+// cache keys of the fragments that render it. Templates included with the
+// template action, or with the partial and cache helpers, are followed as
+// long as their name is a constant.
+func includeSums(sums map[string]string, templates *template.Template, texts *texttemplate.Template) map[string]string {
+	deps := make(map[string][]string)
+	for _, t := range templates.Templates() {
+		if t.Tree != nil {
+			deps[t.Name()] = includes(t.Tree.Root, nil)
+		}
+	}
+	for _, t := range texts.Templates() {
+		if t.Tree != nil {
+			deps[t.Name()] = includes(t.Tree.Root, nil)
+		}
+	}
+
+	folded := make(map[string]string, len(sums))
+	for name := range sums {
+		h := sha256.New()
+		seen := make(map[string]bool)
+
+		var walk func(name string)
+		walk = func(name string) {
+			if seen[name] {
+				return
+			}
+			seen[name] = true
+			fmt.Fprintf(h, "%s\x00%s\x00", name, sums[name])
+			for _, dep := range deps[name] {
+				walk(dep)
+			}
+		}
+		walk(name)
+
+		folded[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return folded
+}
+
+// includes appends the names of the templates included by the given node.
+func includes(node parse.Node, names []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return names
+		}
+		for _, node := range n.Nodes {
+			names = includes(node, names)
+		}
+	case *parse.ActionNode:
+		names = includes(n.Pipe, names)
+	case *parse.IfNode:
+		names = includesBranch(&n.BranchNode, names)
+	case *parse.RangeNode:
+		names = includesBranch(&n.BranchNode, names)
+	case *parse.WithNode:
+		names = includesBranch(&n.BranchNode, names)
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+		names = includes(n.Pipe, names)
+	case *parse.PipeNode:
+		if n == nil {
+			return names
+		}
+		for _, cmd := range n.Cmds {
+			names = includes(cmd, names)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) > 1 {
+			if fn, ok := n.Args[0].(*parse.IdentifierNode); ok && (fn.Ident == "partial" || fn.Ident == "cache") {
+				if s, ok := n.Args[1].(*parse.StringNode); ok {
+					names = append(names, s.Text)
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			names = includes(arg, names)
+		}
+	}
+	return names
+}
+
+func includesBranch(n *parse.BranchNode, names []string) []string {
+	names = includes(n.Pipe, names)
+	names = includes(n.List, names)
+	return includes(n.ElseList, names)
+}
@@ -0,0 +1,179 @@
+package turbo_test
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bentranter/turbo"
+)
+
+type cacheUser struct {
+	ID   int
+	Name string
+}
+
+func TestRender_Cache(t *testing.T) {
+	metrics := turbo.NewMetrics()
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/cache",
+		Layout:    "layout",
+		Cache:     turbo.NewLRU(10),
+		Hooks:     []turbo.Hook{metrics.Hook()},
+	})
+
+	renderUser := func(t *testing.T, user cacheUser) string {
+		t.Helper()
+
+		res := httptest.NewRecorder()
+		if err := render.HTML(res, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "content", user); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		return res.Body.String()
+	}
+
+	assertBody := func(t *testing.T, user cacheUser, expected string) {
+		t.Helper()
+
+		if body := renderUser(t, user); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	}
+
+	assertBody(t, cacheUser{ID: 1, Name: "Ben"}, "<aside>Ben</aside>")
+
+	// The name isn't part of the key, so the fragment is served from the
+	// cache.
+	assertBody(t, cacheUser{ID: 1, Name: "Benjamin"}, "<aside>Ben</aside>")
+	assertBody(t, cacheUser{ID: 2, Name: "Ana"}, "<aside>Ana</aside>")
+
+	render.Invalidate("_sidebar", 1)
+	assertBody(t, cacheUser{ID: 1, Name: "Benjamin"}, "<aside>Benjamin</aside>")
+	assertBody(t, cacheUser{ID: 2, Name: "Anastasia"}, "<aside>Ana</aside>")
+
+	render.InvalidateAll("_sidebar")
+	assertBody(t, cacheUser{ID: 1, Name: "B"}, "<aside>B</aside>")
+	assertBody(t, cacheUser{ID: 2, Name: "A"}, "<aside>A</aside>")
+
+	if hits, misses := metrics.CacheStats("_sidebar"); hits != 2 || misses != 5 {
+		t.Fatalf("expected 2 hits and 5 misses but got %d and %d", hits, misses)
+	}
+
+	res := httptest.NewRecorder()
+	metrics.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if expected := `turbo_fragment_cache_total{template="_sidebar",result="hit"} 2`; !strings.Contains(res.Body.String(), expected) {
+		t.Fatalf("expected metrics to contain %s but got %s", expected, res.Body.String())
+	}
+
+	t.Run("fragments are cached per locale", func(t *testing.T) {
+		i18n, err := turbo.NewI18n(os.DirFS("fixtures/i18n/locales"), "en")
+		if err != nil {
+			t.Fatalf("unexpected error loading catalogs: %v", err)
+		}
+		render := turbo.New(turbo.Options{
+			Roots: []fs.FS{
+				fstest.MapFS{
+					"content.tmpl": {Data: []byte(`{{ cache "_title" . }}`)},
+					"_title.tmpl":  {Data: []byte(`{{ t "title" }}`)},
+				},
+			},
+			Cache: turbo.NewLRU(10),
+			I18n:  i18n,
+		})
+
+		renderLocale := func(locale string) string {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Language", locale)
+			if err := render.HTML(res, req, http.StatusOK, "content", nil); err != nil {
+				t.Fatalf("unexpected error rendering template: %v", err)
+			}
+			return res.Body.String()
+		}
+
+		for _, tt := range []struct{ locale, expected string }{
+			{"en", "Users"},
+			{"fr", "Utilisateurs"},
+			{"en", "Users"},
+		} {
+			if body := renderLocale(tt.locale); body != tt.expected {
+				t.Fatalf("expected %s in locale %s but got %s", tt.expected, tt.locale, body)
+			}
+		}
+	})
+
+	t.Run("changing an included template changes the key", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"content.tmpl": {Data: []byte(`{{ cache "_card" . }}`)},
+			"_card.tmpl":   {Data: []byte(`<div>{{ template "_name" . }}</div>`)},
+			"_name.tmpl":   {Data: []byte(`{{ . }}`)},
+		}
+		render := turbo.New(turbo.Options{
+			Roots:         []fs.FS{fsys},
+			Cache:         turbo.NewLRU(10),
+			IsDevelopment: true,
+		})
+
+		assertCard := func(expected string) {
+			t.Helper()
+
+			res := httptest.NewRecorder()
+			render.HTML(res, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "content", "Ben")
+			if body := res.Body.String(); body != expected {
+				t.Fatalf("expected %s but got %s", expected, body)
+			}
+		}
+
+		assertCard("<div>Ben</div>")
+		fsys["_name.tmpl"] = &fstest.MapFile{Data: []byte(`<b>{{ . }}</b>`)}
+		assertCard("<div><b>Ben</b></div>")
+	})
+
+	t.Run("without a cache", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Directory: "fixtures/cache",
+			Layout:    "layout",
+		})
+
+		for _, name := range []string{"Ben", "Benjamin"} {
+			res := httptest.NewRecorder()
+			render.HTML(res, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "content", cacheUser{ID: 1, Name: name})
+			if expected := "<aside>" + name + "</aside>"; res.Body.String() != expected {
+				t.Fatalf("expected %s but got %s", expected, res.Body.String())
+			}
+		}
+	})
+}
+
+func TestLRU(t *testing.T) {
+	c := turbo.NewLRU(2)
+	c.Set("a", "1")
+	c.Set("b", "2")
+
+	// Reading a makes b the least recently used.
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Fatalf("expected a to be cached but got %q", v)
+	}
+	c.Set("c", "3")
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 fragments but got %d", c.Len())
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected a to be deleted")
+	}
+
+	c.Clear()
+	if c.Len() != 0 {
+		t.Fatalf("expected cache to be empty but got %d fragments", c.Len())
+	}
+}
@@ -0,0 +1,341 @@
+package turbo
+
+import (
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// CheckError is the error returned by Check. It holds every problem that was
+// found in the templates, not just the first one.
+type CheckError struct {
+	Problems []string
+}
+
+func (e *CheckError) Error() string {
+	return "turbo: template check failed:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// Expect declares the type of the binding that the named template is
+// rendered with, so that Check can verify that the template only accesses
+// fields and methods that exist on it.
+//
+//	render.Expect("users/show", &User{})
+func (r *Render) Expect(name string, binding interface{}) {
+	if r.expect == nil {
+		r.expect = make(map[string]reflect.Type)
+	}
+	r.expect[name] = reflect.TypeOf(binding)
+}
+
+// Check verifies every template that was given a binding type with Expect.
+// It walks the parse tree of each template, as well as the templates it
+// includes, and reports any access to a field or method that doesn't exist
+// on the type it is used on.
+//
+// Values whose type can't be known ahead of time, like interfaces and the
+// results of helper functions, aren't checked any further.
+func (r *Render) Check() error {
+	c := &checker{
+		templates: r.set("").templates,
+		checked:   make(map[checked]bool),
+	}
+
+	// Check the templates in order, so that the problems are reported in
+	// the same order every time.
+	names := make([]string, 0, len(r.expect))
+	for name := range r.expect {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		typ := r.expect[name]
+		tpl := c.templates.Lookup(name)
+		if tpl == nil || tpl.Tree == nil {
+			c.problems = append(c.problems, fmt.Sprintf("%s: template not found", name))
+			continue
+		}
+		c.checkTemplate(tpl.Tree, value{typ: typ})
+	}
+
+	if len(c.problems) > 0 {
+		return &CheckError{Problems: c.problems}
+	}
+	return nil
+}
+
+// checked is a template that has been checked against the given value, so
+// that recursive templates don't recurse forever.
+type checked struct {
+	name string
+	v    value
+}
+
+// value is what is known about a value ahead of time. A nil type means that
+// the type isn't known, and so anything goes. Like the template engine, the
+// methods of the pointer can only be called on values that are addressable,
+// which are the ones reached through a pointer or a slice.
+type value struct {
+	typ  reflect.Type
+	addr bool
+}
+
+type checker struct {
+	templates *template.Template
+	checked   map[checked]bool
+	problems  []string
+}
+
+// scope is the state of a template that is being checked.
+type scope struct {
+	tree *parse.Tree
+	dot  value
+	vars map[string]value
+}
+
+func (s *scope) with(dot value) *scope {
+	vars := make(map[string]value, len(s.vars))
+	for k, v := range s.vars {
+		vars[k] = v
+	}
+	return &scope{tree: s.tree, dot: dot, vars: vars}
+}
+
+func (c *checker) checkTemplate(tree *parse.Tree, v value) {
+	key := checked{name: tree.Name, v: v}
+	if c.checked[key] {
+		return
+	}
+	c.checked[key] = true
+
+	s := &scope{
+		tree: tree,
+		dot:  v,
+		vars: map[string]value{"$": v},
+	}
+	c.walk(s, tree.Root)
+}
+
+func (c *checker) errorf(s *scope, n parse.Node, format string, args ...interface{}) {
+	location, _ := s.tree.ErrorContext(n)
+	c.problems = append(c.problems, location+": "+fmt.Sprintf(format, args...))
+}
+
+func (c *checker) walk(s *scope, n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, node := range n.Nodes {
+			c.walk(s, node)
+		}
+
+	case *parse.ActionNode:
+		c.pipe(s, n.Pipe)
+
+	case *parse.IfNode:
+		c.pipe(s, n.Pipe)
+		c.walk(s, n.List)
+		c.walk(s, n.ElseList)
+
+	case *parse.WithNode:
+		inner := s.with(s.dot)
+		inner.dot = c.pipe(inner, n.Pipe)
+		c.walk(inner, n.List)
+		c.walk(s, n.ElseList)
+
+	case *parse.RangeNode:
+		inner := s.with(s.dot)
+		v := c.pipeResult(inner, n.Pipe)
+		key, elem := rangeValues(v)
+
+		switch len(n.Pipe.Decl) {
+		case 1:
+			inner.vars[n.Pipe.Decl[0].Ident[0]] = elem
+		case 2:
+			inner.vars[n.Pipe.Decl[0].Ident[0]] = key
+			inner.vars[n.Pipe.Decl[1].Ident[0]] = elem
+		}
+		inner.dot = elem
+		c.walk(inner, n.List)
+		c.walk(s, n.ElseList)
+
+	case *parse.TemplateNode:
+		v := s.dot
+		if n.Pipe != nil {
+			v = c.pipe(s, n.Pipe)
+		}
+		if tpl := c.templates.Lookup(n.Name); tpl != nil && tpl.Tree != nil {
+			c.checkTemplate(tpl.Tree, v)
+		}
+	}
+}
+
+// pipe checks the pipeline, declares its variables, and returns its result.
+func (c *checker) pipe(s *scope, pipe *parse.PipeNode) value {
+	v := c.pipeResult(s, pipe)
+	for _, decl := range pipe.Decl {
+		s.vars[decl.Ident[0]] = v
+	}
+	return v
+}
+
+// pipeResult checks the pipeline and returns its result, which is the
+// result of its last command.
+func (c *checker) pipeResult(s *scope, pipe *parse.PipeNode) value {
+	if pipe == nil {
+		return value{}
+	}
+
+	var v value
+	for _, cmd := range pipe.Cmds {
+		v = c.command(s, cmd)
+	}
+	return v
+}
+
+func (c *checker) command(s *scope, cmd *parse.CommandNode) value {
+	// Every argument has to be valid, but only the first one decides the
+	// result of the command. If it's a function, we don't know it.
+	var v value
+	for i, arg := range cmd.Args {
+		a := c.arg(s, arg)
+		if i == 0 {
+			v = a
+		}
+	}
+	return v
+}
+
+func (c *checker) arg(s *scope, n parse.Node) value {
+	switch n := n.(type) {
+	case *parse.DotNode:
+		return s.dot
+	case *parse.FieldNode:
+		return c.fields(s, n, s.dot, n.Ident)
+	case *parse.VariableNode:
+		v, ok := s.vars[n.Ident[0]]
+		if !ok {
+			// The parser already rejects undefined variables, so this is
+			// one we lost track of.
+			return value{}
+		}
+		return c.fields(s, n, v, n.Ident[1:])
+	case *parse.ChainNode:
+		var v value
+		if pipe, ok := n.Node.(*parse.PipeNode); ok {
+			v = c.pipeResult(s, pipe)
+		} else {
+			v = c.arg(s, n.Node)
+		}
+		return c.fields(s, n, v, n.Field)
+	case *parse.PipeNode:
+		return c.pipeResult(s.with(s.dot), n)
+	case *parse.StringNode:
+		return value{typ: reflect.TypeOf("")}
+	case *parse.BoolNode:
+		return value{typ: reflect.TypeOf(false)}
+	case *parse.NumberNode:
+		switch {
+		case n.IsInt:
+			return value{typ: reflect.TypeOf(0)}
+		case n.IsFloat:
+			return value{typ: reflect.TypeOf(0.0)}
+		}
+	}
+	return value{}
+}
+
+// fields resolves each field in the chain, starting from the given value.
+func (c *checker) fields(s *scope, n parse.Node, v value, idents []string) value {
+	for _, ident := range idents {
+		if v.typ == nil {
+			return value{}
+		}
+
+		next, ok := field(v, ident)
+		if !ok {
+			c.errorf(s, n, "can't evaluate field %s in type %s", ident, v.typ)
+			return value{}
+		}
+		v = next
+	}
+	return v
+}
+
+// field returns the field or method with the given name on the given value.
+// Its type is nil if it can't be known.
+func field(v value, name string) (value, bool) {
+	typ := v.typ
+	if typ.Kind() == reflect.Interface {
+		return value{}, true
+	}
+
+	// Like the template engine, look methods up on the pointer when it
+	// can take the address of the value.
+	methods := typ
+	if typ.Kind() != reflect.Ptr && v.addr {
+		methods = reflect.PtrTo(typ)
+	}
+	if m, ok := methods.MethodByName(name); ok {
+		if m.Type.NumOut() == 0 {
+			return value{}, false
+		}
+		return value{typ: m.Type.Out(0)}, true
+	}
+
+	// Values reached through a pointer are addressable.
+	addr := v.addr
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		addr = true
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		return value{}, true
+	case reflect.Struct:
+		if f, ok := typ.FieldByName(name); ok && f.PkgPath == "" {
+			return value{typ: f.Type, addr: addr}, true
+		}
+	case reflect.Map:
+		if typ.Key().Kind() == reflect.String {
+			return value{typ: typ.Elem()}, true
+		}
+	}
+	return value{}, false
+}
+
+// rangeValues returns the key and element of ranging over the given value.
+// The elements of slices, and of arrays that are addressable themselves, are
+// addressable.
+func rangeValues(v value) (key, elem value) {
+	typ, addr := v.typ, v.addr
+	if typ == nil {
+		return value{}, value{}
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		addr = true
+	}
+
+	switch typ.Kind() {
+	case reflect.Array:
+		return value{typ: reflect.TypeOf(0)}, value{typ: typ.Elem(), addr: addr}
+	case reflect.Slice:
+		return value{typ: reflect.TypeOf(0)}, value{typ: typ.Elem(), addr: true}
+	case reflect.Map:
+		return value{typ: typ.Key()}, value{typ: typ.Elem()}
+	case reflect.Chan:
+		return value{typ: typ.Elem()}, value{typ: typ.Elem()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value{typ: typ}, value{typ: typ}
+	}
+	return value{}, value{}
+}
@@ -0,0 +1,123 @@
+package turbo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+type checkUser struct {
+	Name    string
+	Profile *checkProfile
+	Posts   []checkPost
+	Pinned  checkPost
+	Meta    []interface{}
+}
+
+func (u *checkUser) Greeting(greeting string) string {
+	return greeting + " " + u.Name
+}
+
+type checkProfile struct {
+	Bio string
+}
+
+type checkPost struct {
+	Title string
+}
+
+func (p *checkPost) Slug() string {
+	return strings.ToLower(p.Title)
+}
+
+func TestRender_Check(t *testing.T) {
+	t.Run("check valid template", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Directory: "fixtures/check",
+		})
+		render.Expect("users/show", &checkUser{})
+
+		if err := render.Check(); err != nil {
+			t.Fatalf("unexpected error checking template: %v", err)
+		}
+	})
+
+	t.Run("check template with invalid fields", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Directory: "fixtures/check",
+		})
+		render.Expect("users/broken", checkUser{})
+
+		err := render.Check()
+		if err == nil {
+			t.Fatalf("expected error checking template but got none")
+		}
+		checkErr, ok := err.(*turbo.CheckError)
+		if !ok {
+			t.Fatalf("expected error to be of type check error, but got %#v", err)
+		}
+
+		expected := []string{
+			"users/broken:1:7: can't evaluate field Nmae",
+			"users/broken:2:21: can't evaluate field Body",
+			"users/broken:3:30: can't evaluate field Age",
+			"users/_post:1:12: can't evaluate field Title in type *turbo_test.checkProfile",
+		}
+		if len(checkErr.Problems) != len(expected) {
+			t.Fatalf("expected %d problems but got %d: %v", len(expected), len(checkErr.Problems), checkErr.Problems)
+		}
+		for i, problem := range checkErr.Problems {
+			if !strings.HasPrefix(problem, expected[i]) {
+				t.Fatalf("expected problem to start with %q but got %q", expected[i], problem)
+			}
+		}
+	})
+
+	t.Run("check pointer methods", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Directory: "fixtures/check",
+		})
+		render.Expect("users/methods", &checkUser{})
+
+		if err := render.Check(); err != nil {
+			t.Fatalf("unexpected error checking template: %v", err)
+		}
+
+		// The template engine can't take the address of a value binding,
+		// or of its fields, but it can of the elements of a slice.
+		render.Expect("users/methods", checkUser{})
+
+		err := render.Check()
+		checkErr, ok := err.(*turbo.CheckError)
+		if !ok {
+			t.Fatalf("expected error to be of type check error, but got %#v", err)
+		}
+
+		expected := []string{
+			"users/methods:1:3: can't evaluate field Greeting in type turbo_test.checkUser",
+			"users/methods:3:10: can't evaluate field Slug in type turbo_test.checkPost",
+		}
+		if len(checkErr.Problems) != len(expected) {
+			t.Fatalf("expected %d problems but got %d: %v", len(expected), len(checkErr.Problems), checkErr.Problems)
+		}
+		for i, problem := range checkErr.Problems {
+			if !strings.HasPrefix(problem, expected[i]) {
+				t.Fatalf("expected problem to start with %q but got %q", expected[i], problem)
+			}
+		}
+	})
+
+	t.Run("check template with invalid data", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Directory: "fixtures/error",
+		})
+		render.Expect("badData", &struct {
+			V interface{}
+		}{})
+
+		if err := render.Check(); err == nil || !strings.Contains(err.Error(), "can't evaluate field Data") {
+			t.Fatalf("expected error about field Data but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package turbo
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// newRender starts a render with the given context, using a clone of the
+// templates of the given theme that no other render is using.
+func (r *Render) newRender(ctx context.Context, theme string) (*renderContext, error) {
+	set := r.set(theme)
+	templates, clones := set.templates, set.clones
+
+	rc := &renderContext{ctx: ctx, theme: theme, meta: set.meta, clones: clones}
+	if rc.ctx == nil {
+		rc.ctx = context.Background()
+	}
+
+	if tpl, ok := clones.Get().(*template.Template); ok {
+		rc.templates = tpl
+		return rc, nil
+	}
+
+	// A template set can't be cloned after it has been executed, which is
+	// why the original is never executed.
+	tpl, err := templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	rc.templates = tpl
+	return rc, nil
+}
+
+// release returns the templates of the render to the pool they came from.
+func (r *Render) release(rc *renderContext) {
+	rc.clones.Put(rc.templates)
+}
+
+// placeholderRequest is the request that Options.RequestFuncs are called
+// with when the templates are compiled.
+func placeholderRequest() *http.Request {
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: "/"},
+		Header: http.Header{},
+	}
+	return req.WithContext(context.Background())
+}
+
+// placeholderWriter is the response writer that Options.RequestFuncs are
+// called with when the templates are compiled. It discards everything.
+type placeholderWriter struct{}
+
+func (placeholderWriter) Header() http.Header         { return http.Header{} }
+func (placeholderWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (placeholderWriter) WriteHeader(int)             {}
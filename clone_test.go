@@ -0,0 +1,79 @@
+package turbo_test
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_RequestFuncs(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/funcs",
+		Layout:    "layout",
+		RequestFuncs: []func(w http.ResponseWriter, r *http.Request) template.FuncMap{
+			func(w http.ResponseWriter, r *http.Request) template.FuncMap {
+				return template.FuncMap{
+					"user": func() string {
+						return r.URL.Query().Get("user")
+					},
+				}
+			},
+		},
+	})
+
+	t.Run("request funcs work in layouts", func(t *testing.T) {
+		const expected = `ben|<p>ben:test</p>`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/?user=ben", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+
+	t.Run("request funcs work in partials", func(t *testing.T) {
+		const expected = `<p>ben:test</p>`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/?user=ben", nil)
+		actual, err := render.String(res, req, "content", "test", true)
+		if err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		if actual != expected {
+			t.Fatalf("expected %s but got %s", expected, actual)
+		}
+	})
+
+	t.Run("concurrent renders see their own request", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				user := strconv.Itoa(i)
+				expected := user + `|<p>` + user + `:test</p>`
+
+				res := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/?user="+user, nil)
+				if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+					t.Errorf("unexpected error rendering template: %v", err)
+					return
+				}
+				if body := res.Body.String(); body != expected {
+					t.Errorf("expected %s but got %s", expected, body)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// liveReloadPath is the path of the endpoint that tells the browser to
+// reload, as server-sent events.
+const liveReloadPath = "/_turbo/livereload"
+
+// liveReloadScript is injected into every HTML page served by the dev
+// server. It guards against being run more than once, since Turbolinks runs
+// the scripts in the body on every visit.
+const liveReloadScript = `<script>(function() {
+  if (window.__turboLiveReload) return;
+  window.__turboLiveReload = new EventSource("` + liveReloadPath + `");
+  window.__turboLiveReload.onmessage = function() { window.location.reload(); };
+})();</script>`
+
+// errorPage is shown in the browser when the app fails to build.
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Build failed</title>
+  <style>body { font-family: sans-serif; margin: 2em; } pre { background: #fee; padding: 1em; overflow: auto; }</style>
+</head>
+<body>
+  <h1>Build failed</h1>
+  <pre>{{ . }}</pre>
+</body>
+</html>`))
+
+// dev runs the app, and rebuilds and restarts it whenever a Go file changes.
+// Requests are proxied to the app, and held while it is being rebuilt.
+func dev(args []string) error {
+	fs := flag.NewFlagSet("dev", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: turbo dev [flags] [package]\n\n")
+		fs.PrintDefaults()
+	}
+	var (
+		addr     = fs.String("addr", ":3000", "address to serve the app on")
+		appPort  = fs.Int("app-port", 3001, "port the app listens on, passed to it as $PORT")
+		exts     = fs.String("ext", ".html,.tmpl", "comma separated list of template extensions")
+		interval = fs.Duration("interval", 500*time.Millisecond, "how often to check for changes")
+	)
+	fs.Parse(args)
+
+	pkg := fs.Arg(0)
+	if pkg == "" {
+		pkg = "."
+	}
+
+	tmp, err := os.MkdirTemp("", "turbo-dev")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	d := newDevServer(pkg, filepath.Join(tmp, "app"), "127.0.0.1:"+strconv.Itoa(*appPort))
+	defer d.stop()
+
+	w := &watcher{
+		root:      ".",
+		goExts:    []string{".go"},
+		otherExts: splitList(*exts),
+	}
+	w.scan()
+
+	// Mark the first build as started before serving, so that the first
+	// requests wait for it instead of being proxied to an app that isn't
+	// running yet.
+	d.startBuild()
+	go d.finishBuild()
+	go func() {
+		for range time.Tick(*interval) {
+			switch w.changes() {
+			case goChanged:
+				d.rebuild()
+			case otherChanged:
+				// Templates are recompiled by the app itself in
+				// development mode, so the page just has to reload.
+				d.reload()
+			}
+		}
+	}()
+
+	log.Printf("turbo dev: serving %s on %s", pkg, *addr)
+	return http.ListenAndServe(*addr, d)
+}
+
+type devServer struct {
+	pkg     string
+	binary  string
+	appAddr string
+
+	// build builds and restarts the app, and returns the error to show
+	// in the browser if that fails.
+	build func() string
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	building bool
+	buildErr string
+	cmd      *exec.Cmd
+	exited   chan struct{}
+
+	clientsMu sync.Mutex
+	clients   map[chan struct{}]bool
+}
+
+func newDevServer(pkg, binary, appAddr string) *devServer {
+	d := &devServer{
+		pkg:     pkg,
+		binary:  binary,
+		appAddr: appAddr,
+		clients: make(map[chan struct{}]bool),
+	}
+	d.build = d.buildApp
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// rebuild builds the app, and restarts it if the build succeeds. Requests
+// that arrive in the meantime wait for it to finish.
+func (d *devServer) rebuild() {
+	if d.startBuild() {
+		d.finishBuild()
+	}
+}
+
+// startBuild marks a build as started, so that requests wait for it. It
+// returns false if there is one already.
+func (d *devServer) startBuild() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.building {
+		return false
+	}
+	d.building = true
+	return true
+}
+
+// finishBuild runs the build that was started, and lets the requests that
+// are waiting for it through.
+func (d *devServer) finishBuild() {
+	buildErr := d.build()
+
+	d.mu.Lock()
+	d.building = false
+	d.buildErr = buildErr
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	d.reload()
+}
+
+// buildApp builds the app with the go tool, and restarts it if the build
+// succeeds.
+func (d *devServer) buildApp() string {
+	log.Printf("turbo dev: building %s", d.pkg)
+	out, err := exec.Command("go", "build", "-o", d.binary, d.pkg).CombinedOutput()
+	if err != nil {
+		buildErr := string(out)
+		if buildErr == "" {
+			buildErr = err.Error()
+		}
+		log.Printf("turbo dev: build failed:\n%s", buildErr)
+		return buildErr
+	}
+
+	d.stop()
+	if err := d.start(); err != nil {
+		log.Printf("turbo dev: %v", err)
+		return err.Error()
+	}
+	return ""
+}
+
+// start starts the app, and waits for it to accept connections.
+func (d *devServer) start() error {
+	_, port, _ := net.SplitHostPort(d.appAddr)
+
+	cmd := exec.Command(d.binary)
+	cmd.Env = append(os.Environ(), "PORT="+port)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	d.mu.Lock()
+	d.cmd = cmd
+	d.exited = exited
+	d.mu.Unlock()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-exited:
+			return fmt.Errorf("app exited on start: %v", cmd.ProcessState)
+		default:
+		}
+
+		if conn, err := net.DialTimeout("tcp", d.appAddr, 100*time.Millisecond); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("app isn't listening on %s, does it use $PORT?", d.appAddr)
+}
+
+// stop stops the app if it's running, giving it a moment to shut down
+// gracefully.
+func (d *devServer) stop() {
+	d.mu.Lock()
+	cmd, exited := d.cmd, d.exited
+	d.cmd, d.exited = nil, nil
+	d.mu.Unlock()
+
+	if cmd == nil {
+		return
+	}
+
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-exited:
+	case <-time.After(3 * time.Second):
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// reload tells every connected browser to reload the page.
+func (d *devServer) reload() {
+	d.clientsMu.Lock()
+	defer d.clientsMu.Unlock()
+
+	for c := range d.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (d *devServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == liveReloadPath {
+		d.serveLiveReload(w, r)
+		return
+	}
+
+	// Hold the request until the app is ready, rather than fail it.
+	d.mu.Lock()
+	for d.building {
+		d.cond.Wait()
+	}
+	buildErr := d.buildErr
+	d.mu.Unlock()
+
+	if buildErr != "" {
+		buf := &bytes.Buffer{}
+		errorPage.Execute(buf, buildErr)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(injectLiveReload(buf.Bytes()))
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: d.appAddr})
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+
+		// We need the body uncompressed to inject the script into it.
+		r.Header.Del("Accept-Encoding")
+	}
+	proxy.ModifyResponse = func(res *http.Response) error {
+		if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") || res.Header.Get("Content-Encoding") != "" {
+			return nil
+		}
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		body = injectLiveReload(body)
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		res.ContentLength = int64(len(body))
+		res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		return nil
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// serveLiveReload sends an event to the browser each time it should reload.
+func (d *devServer) serveLiveReload(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan struct{}, 1)
+	d.clientsMu.Lock()
+	d.clients[c] = true
+	d.clientsMu.Unlock()
+	defer func() {
+		d.clientsMu.Lock()
+		delete(d.clients, c)
+		d.clientsMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-c:
+			io.WriteString(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// injectLiveReload adds the live reload script to the end of the body of the
+// given HTML page, or to the end of the page if it has no closing body tag.
+func injectLiveReload(page []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i == -1 {
+		return append(page, liveReloadScript...)
+	}
+
+	out := make([]byte, 0, len(page)+len(liveReloadScript))
+	out = append(out, page[:i]...)
+	out = append(out, liveReloadScript...)
+	return append(out, page[i:]...)
+}
+
+type change int
+
+const (
+	noChange change = iota
+	otherChanged
+	goChanged
+)
+
+// watcher polls the files under a directory for changes. Polling is slower
+// than using OS notifications, but works the same everywhere.
+type watcher struct {
+	root      string
+	goExts    []string
+	otherExts []string
+	files     map[string]time.Time
+}
+
+// changes reports what kind of file changed since the last call. A change
+// to a Go file wins, since the rebuild reloads the page as well.
+func (w *watcher) changes() change {
+	old := w.files
+	w.scan()
+
+	result := noChange
+	for name, modTime := range w.files {
+		if prev, ok := old[name]; !ok || !prev.Equal(modTime) {
+			result = maxChange(result, w.kind(name))
+		}
+	}
+	for name := range old {
+		if _, ok := w.files[name]; !ok {
+			result = maxChange(result, w.kind(name))
+		}
+	}
+	return result
+}
+
+func maxChange(a, b change) change {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (w *watcher) kind(name string) change {
+	ext := filepath.Ext(name)
+	for _, e := range w.goExts {
+		if ext == e {
+			return goChanged
+		}
+	}
+	return otherChanged
+}
+
+// scan records the modification time of every watched file.
+func (w *watcher) scan() {
+	files := make(map[string]time.Time)
+	filepath.Walk(w.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if path != w.root && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := filepath.Ext(name)
+		for _, e := range append(w.goExts, w.otherExts...) {
+			if ext == e {
+				files[path] = info.ModTime()
+				break
+			}
+		}
+		return nil
+	})
+	w.files = files
+}
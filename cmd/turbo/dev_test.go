@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectLiveReload(t *testing.T) {
+	tests := []struct {
+		page     string
+		expected string
+	}{
+		{"<html><body><p>hi</p></body></html>", "<html><body><p>hi</p>" + liveReloadScript + "</body></html>"},
+		{"<HTML><BODY></BODY></HTML>", "<HTML><BODY>" + liveReloadScript + "</BODY></HTML>"},
+		{"<p>partial</p>", "<p>partial</p>" + liveReloadScript},
+	}
+
+	for _, tt := range tests {
+		if actual := string(injectLiveReload([]byte(tt.page))); actual != tt.expected {
+			t.Errorf("expected %s but got %s", tt.expected, actual)
+		}
+	}
+}
+
+func TestDevServer(t *testing.T) {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<html><body><p>app</p></body></html>")
+	}))
+	defer app.Close()
+
+	serve := func(d *devServer) <-chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			res := httptest.NewRecorder()
+			d.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+			done <- res
+		}()
+		return done
+	}
+
+	t.Run("requests wait for the build", func(t *testing.T) {
+		release := make(chan struct{})
+		d := newDevServer(".", "", app.Listener.Addr().String())
+		d.build = func() string {
+			<-release
+			return ""
+		}
+
+		if !d.startBuild() {
+			t.Fatalf("expected build to start")
+		}
+		if d.startBuild() {
+			t.Fatalf("expected only one build at a time")
+		}
+		go d.finishBuild()
+
+		done := serve(d)
+		select {
+		case res := <-done:
+			t.Fatalf("expected request to wait for the build but got %d", res.Code)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		res := <-done
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusOK, res.Code)
+		}
+		if expected := "<html><body><p>app</p>" + liveReloadScript + "</body></html>"; res.Body.String() != expected {
+			t.Fatalf("expected %s but got %s", expected, res.Body.String())
+		}
+	})
+
+	t.Run("failed builds show the error", func(t *testing.T) {
+		d := newDevServer(".", "", app.Listener.Addr().String())
+		d.build = func() string {
+			return "./main.go:10:2: undefined: x"
+		}
+		d.rebuild()
+
+		res := <-serve(d)
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusInternalServerError, res.Code)
+		}
+		body := res.Body.String()
+		if !strings.Contains(body, "<pre>./main.go:10:2: undefined: x</pre>") {
+			t.Fatalf("expected build error in page but got %s", body)
+		}
+		if !strings.Contains(body, liveReloadScript+"</body>") {
+			t.Fatalf("expected live reload script in page but got %s", body)
+		}
+	})
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("main.go", "package main", start)
+	write("templates/home.tmpl", "home", start)
+	write(".git/HEAD", "ref", start)
+
+	w := &watcher{root: dir, goExts: []string{".go"}, otherExts: []string{".tmpl"}}
+	w.scan()
+
+	if c := w.changes(); c != noChange {
+		t.Fatalf("expected no change but got %d", c)
+	}
+
+	write("templates/home.tmpl", "changed", start.Add(time.Minute))
+	if c := w.changes(); c != otherChanged {
+		t.Fatalf("expected template change but got %d", c)
+	}
+
+	write("templates/home.tmpl", "changed again", start.Add(2*time.Minute))
+	write("handler.go", "package main", start)
+	if c := w.changes(); c != goChanged {
+		t.Fatalf("expected Go change but got %d", c)
+	}
+
+	os.Remove(filepath.Join(dir, "main.go"))
+	if c := w.changes(); c != goChanged {
+		t.Fatalf("expected Go change for removed file but got %d", c)
+	}
+
+	if len(w.files) != 2 || strings.Contains(strings.Join(keys(w.files), ","), ".git") {
+		t.Fatalf("expected hidden directories to be skipped but got %v", keys(w.files))
+	}
+}
+
+func keys(m map[string]time.Time) []string {
+	var list []string
+	for k := range m {
+		list = append(list, k)
+	}
+	return list
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// scaffold holds the files written by `turbo new` and `turbo generate`. They
+// use [[ ]] as delimiters, since the files themselves are full of {{ }}.
+//
+//go:embed all:scaffold
+var scaffold embed.FS
+
+// newApp writes out a new app that's ready to have resources generated in.
+func newApp(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: turbo new [flags] <module>\n\n")
+		fs.PrintDefaults()
+	}
+	force := fs.Bool("force", false, "overwrite files that already exist")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	module := fs.Arg(0)
+	name := path.Base(module)
+
+	data := map[string]string{
+		"Module": module,
+		"Name":   name,
+	}
+	if err := generate("scaffold/app", name, data, *force, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nCreated %s. To start it, run:\n\n\tcd %s\n\tgo mod tidy\n\tgo run .\n\n", name, name)
+	return nil
+}
+
+// generateResource writes out the handler, templates and tests for a
+// resource in the current directory.
+func generateResource(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: turbo generate [flags] resource <name>\n\n")
+		fs.PrintDefaults()
+	}
+	force := fs.Bool("force", false, "overwrite files that already exist")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || fs.Arg(0) != "resource" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	plural := strings.ToLower(fs.Arg(1))
+	if !isIdentifier(plural) {
+		return fmt.Errorf("%q is not a valid resource name", fs.Arg(1))
+	}
+	singular := singularize(plural)
+
+	data := map[string]string{
+		"Plural":   plural,
+		"Singular": singular,
+		"Var":      varName(singular, plural),
+		"Type":     title(singular),
+		"Handler":  title(plural),
+		"Title":    title(plural),
+	}
+	if singular == plural {
+		// The type already has the name of the handler.
+		data["Handler"] += "Handler"
+	}
+
+	// Put the templates in a directory named after the resource, and name
+	// the Go files after it.
+	rename := func(name string) string {
+		switch {
+		case strings.HasPrefix(name, "templates/"):
+			return path.Join("templates", plural, strings.TrimPrefix(name, "templates/"))
+		case strings.HasPrefix(name, "handler"):
+			return plural + strings.TrimPrefix(name, "handler")
+		}
+		return name
+	}
+	if err := generate("scaffold/resource", ".", data, *force, rename); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nTo serve the %s, add this to main.go:\n\n\tNew%s(render).Register(mux)\n\n", plural, data["Handler"])
+	return nil
+}
+
+// generate executes every file in the given scaffold directory, and writes
+// the results to the given directory.
+func generate(dir, dest string, data interface{}, force bool, rename func(string) string) error {
+	return fs.WalkDir(scaffold, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		buf, err := scaffold.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(name).Delims("[[", "]]").Parse(string(buf))
+		if err != nil {
+			return err
+		}
+
+		out := &bytes.Buffer{}
+		if err := tmpl.Execute(out, data); err != nil {
+			return err
+		}
+
+		rel := strings.TrimSuffix(strings.TrimPrefix(name, dir+"/"), ".txt")
+		if rename != nil {
+			rel = rename(rel)
+		}
+
+		// Gofmt the Go files, since substituting names messes with the
+		// alignment.
+		contents := out.Bytes()
+		if strings.HasSuffix(rel, ".go") {
+			if contents, err = format.Source(contents); err != nil {
+				return fmt.Errorf("formatting %s: %v", rel, err)
+			}
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		if _, err := os.Stat(target); err == nil && !force {
+			return fmt.Errorf("%s already exists, use -force to overwrite it", target)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, contents, 0644); err != nil {
+			return err
+		}
+		fmt.Println("create", target)
+		return nil
+	})
+}
+
+// uncountable are the nouns that are the same in the singular and plural,
+// but end in an s.
+var uncountable = map[string]bool{
+	"news":    true,
+	"series":  true,
+	"species": true,
+}
+
+// singularize returns the singular of an English plural, for the simple
+// cases that resource names usually are.
+func singularize(plural string) string {
+	switch {
+	case uncountable[plural]:
+		return plural
+	case strings.HasSuffix(plural, "ies"):
+		return strings.TrimSuffix(plural, "ies") + "y"
+	case strings.HasSuffix(plural, "sses"), strings.HasSuffix(plural, "xes"), strings.HasSuffix(plural, "ches"), strings.HasSuffix(plural, "shes"):
+		return strings.TrimSuffix(plural, "es")
+	case strings.HasSuffix(plural, "ss"):
+		return plural
+	case strings.HasSuffix(plural, "s"):
+		return strings.TrimSuffix(plural, "s")
+	}
+	return plural
+}
+
+// reserved are the names that the variables for single items can't have,
+// since they're predeclared in Go, or the generated handler already uses
+// them.
+var reserved = map[string]bool{
+	// Predeclared types, constants and functions.
+	"any": true, "bool": true, "byte": true, "comparable": true,
+	"complex64": true, "complex128": true, "error": true, "float32": true,
+	"float64": true, "int": true, "int8": true, "int16": true, "int32": true,
+	"int64": true, "rune": true, "string": true, "uint": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"true": true, "false": true, "iota": true, "nil": true,
+	"append": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "copy": true, "delete": true, "imag": true, "len": true,
+	"make": true, "max": true, "min": true, "new": true, "panic": true,
+	"print": true, "println": true, "real": true, "recover": true,
+
+	// The packages and variables of handler.go.txt.
+	"http": true, "sort": true, "strconv": true, "strings": true,
+	"sync": true, "turbo": true, "h": true, "w": true, "r": true, "i": true,
+	"j": true, "id": true, "ok": true, "err": true, "name": true,
+}
+
+// varName returns the name of the variables that hold a single item of the
+// resource with the given singular and plural.
+func varName(singular, plural string) string {
+	// Variables for single items need a name of their own, and one that
+	// is neither a keyword nor shadows anything.
+	if singular == plural || token.IsKeyword(singular) || reserved[singular] {
+		return "item"
+	}
+	return singular
+}
+
+// title upper cases the first letter of s, and the letter after each
+// underscore, ie, "blog_posts" becomes "BlogPosts".
+func title(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isIdentifier reports whether s can be used in Go identifiers and URLs.
+func isIdentifier(s string) bool {
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && (r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
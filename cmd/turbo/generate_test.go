@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestSingularize(t *testing.T) {
+	tests := map[string]string{
+		"users":      "user",
+		"categories": "category",
+		"addresses":  "address",
+		"boxes":      "box",
+		"matches":    "match",
+		"news":       "news",
+		"types":      "type",
+		"access":     "access",
+		"sheep":      "sheep",
+	}
+
+	for plural, expected := range tests {
+		if actual := singularize(plural); actual != expected {
+			t.Errorf("expected singular of %s to be %s but got %s", plural, expected, actual)
+		}
+	}
+}
+
+func TestVarName(t *testing.T) {
+	tests := map[string]string{
+		"users": "user",
+		"sheep": "item",
+		"news":  "item",
+		"types": "item",
+		"maps":  "item",
+		"funcs": "item",
+		"ints":  "item",
+		"ids":   "item",
+	}
+
+	for plural, expected := range tests {
+		if actual := varName(singularize(plural), plural); actual != expected {
+			t.Errorf("expected variable for %s to be %s but got %s", plural, expected, actual)
+		}
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := map[string]string{
+		"users":      "Users",
+		"blog_posts": "BlogPosts",
+	}
+
+	for s, expected := range tests {
+		if actual := title(s); actual != expected {
+			t.Errorf("expected title of %s to be %s but got %s", s, expected, actual)
+		}
+	}
+}
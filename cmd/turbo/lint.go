@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"github.com/bentranter/turbo"
+)
+
+// lint parses every template with the same rules as turbo.New, and reports
+// the problems it finds. In check mode, it fails if there are any.
+func lint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: turbo lint [flags] [directory]\n\n")
+		fs.PrintDefaults()
+	}
+	var (
+		check     = fs.Bool("check", false, "exit with a non-zero status if there are any problems")
+		exts      = fs.String("ext", ".html,.tmpl", "comma separated list of template extensions")
+		funcs     = fs.String("funcs", "", "comma separated list of the names of the app's custom template funcs")
+		delims    = fs.String("delims", "", `space separated left and right action delimiters, ie, "[[ ]]"`)
+		dirDelims = fs.String("dir-delims", "", `comma separated list of delimiters for directories, ie, "vue=[[ ]]"`)
+		markdown  = fs.Bool("markdown", false, "compile .md files as Markdown templates")
+	)
+	fs.Parse(args)
+
+	opts := turbo.Options{
+		Directory:  fs.Arg(0),
+		Extensions: splitList(*exts),
+	}
+	if opts.Directory == "" {
+		opts.Directory = "."
+	}
+
+	if *markdown {
+		opts.Processors = map[string][]turbo.Processor{".md": turbo.MarkdownProcessors}
+	}
+
+	if *delims != "" {
+		d, err := parseDelims(*delims)
+		if err != nil {
+			return err
+		}
+		opts.Delims = d
+	}
+	for _, item := range splitList(*dirDelims) {
+		dir, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid directory delimiters %q, expected dir=left right", item)
+		}
+		d, err := parseDelims(value)
+		if err != nil {
+			return err
+		}
+		if opts.DirDelims == nil {
+			opts.DirDelims = make(map[string]turbo.Delims)
+		}
+		opts.DirDelims[strings.TrimSpace(dir)] = d
+	}
+
+	// Turbo's optional helpers are only added when the app sets Assets or
+	// I18n, which lint can't know about, so they're always available here.
+	opts.Funcs = append(opts.Funcs, (&turbo.Assets{}).Funcs(), (&turbo.I18n{}).Funcs(""))
+
+	// The app's funcs can't be called from here, so stand-ins are enough to
+	// get the templates that use them to parse.
+	if names := splitList(*funcs); len(names) > 0 {
+		stubs := template.FuncMap{}
+		for _, name := range names {
+			stubs[name] = func(...interface{}) interface{} { return nil }
+		}
+		opts.Funcs = append(opts.Funcs, stubs)
+	}
+
+	errs := turbo.Lint(opts)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stdout, err)
+	}
+
+	if *check && len(errs) > 0 {
+		return fmt.Errorf("found %d problem(s) in %s", len(errs), opts.Directory)
+	}
+	return nil
+}
+
+// parseDelims parses a space separated pair of delimiters.
+func parseDelims(s string) (turbo.Delims, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return turbo.Delims{}, fmt.Errorf("invalid delimiters %q, expected left and right separated by a space", s)
+	}
+	return turbo.Delims{Left: fields[0], Right: fields[1]}, nil
+}
+
+// splitList splits a comma separated list, ignoring empty items.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	page := `<p>{{ t "users.title" }} {{ l .Now }} {{ locale }}</p>{{ asset_tag "app.css" }} {{ asset_path "app.js" }} {{ greet }}`
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lint([]string{"-check", "-funcs", "greet", dir}); err != nil {
+		t.Fatalf("expected turbo's helpers and the app's funcs to be defined but got %v", err)
+	}
+	if err := lint([]string{"-check", dir}); err == nil {
+		t.Fatal("expected an error for the undefined func")
+	}
+}
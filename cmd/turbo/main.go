@@ -0,0 +1,62 @@
+// Command turbo is a set of tools for working on apps built with turbo.
+//
+// Usage:
+//
+//	turbo <command> [arguments]
+//
+// The commands are:
+//
+//	lint        report problems with the templates in a directory
+//	new         create a new app
+//	generate    generate the handler, templates and tests for a resource
+//	dev         run the app, rebuilding and reloading it when files change
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage:
+
+	turbo <command> [arguments]
+
+The commands are:
+
+	lint        report problems with the templates in a directory
+	new         create a new app
+	generate    generate the handler, templates and tests for a resource
+	dev         run the app, rebuilding and reloading it when files change
+
+Use "turbo <command> -h" for more information about a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "lint":
+		err = lint(args)
+	case "new":
+		err = newApp(args)
+	case "generate", "g":
+		err = generateResource(args)
+	case "dev":
+		err = dev(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "turbo: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "turbo:", err)
+		os.Exit(1)
+	}
+}
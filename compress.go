@@ -0,0 +1,193 @@
+package turbo
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HandlerOptions configures HandlerWithOptions.
+type HandlerOptions struct {
+	// Compression compresses responses when it is set.
+	Compression *Compression
+}
+
+// Encoder is a content coding that responses can be compressed with.
+type Encoder struct {
+	// Name is the name of the content coding, as it appears in the
+	// Accept-Encoding and Content-Encoding headers, ie, "gzip".
+	Name string
+
+	// New returns a writer that compresses to w.
+	New func(w io.Writer) (io.WriteCloser, error)
+}
+
+// GzipEncoder and DeflateEncoder are the content codings that are available
+// in the standard library. Other codings, like brotli, can be added by
+// appending an Encoder to Compression.Encoders.
+var (
+	GzipEncoder = Encoder{
+		Name: "gzip",
+		New: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		},
+	}
+	DeflateEncoder = Encoder{
+		Name: "deflate",
+		New: func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.DefaultCompression)
+		},
+	}
+)
+
+// DefaultCompressibleTypes are the media types that are compressed when
+// Compression.ContentTypes is empty.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// Compression configures the compression of responses by
+// HandlerWithOptions.
+type Compression struct {
+	// Encoders are the content codings to choose from, in order of
+	// preference when the client accepts more than one equally. Defaults to
+	// gzip and deflate.
+	Encoders []Encoder
+
+	// MinSize is the smallest response, in bytes, that is compressed.
+	// Defaults to 1024.
+	MinSize int
+
+	// ContentTypes are the media types that are compressed. A type can end
+	// in "/*" to match every subtype. Defaults to DefaultCompressibleTypes.
+	ContentTypes []string
+}
+
+func (c *Compression) encoders() []Encoder {
+	if len(c.Encoders) == 0 {
+		return []Encoder{GzipEncoder, DeflateEncoder}
+	}
+	return c.Encoders
+}
+
+func (c *Compression) minSize() int {
+	if c.MinSize == 0 {
+		return 1024
+	}
+	return c.MinSize
+}
+
+// compressible reports whether responses with the given content type are
+// compressed.
+func (c *Compression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	types := c.ContentTypes
+	if len(types) == 0 {
+		types = DefaultCompressibleTypes
+	}
+	for _, t := range types {
+		if t == mediaType || strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate picks the encoder to use for the given Accept-Encoding header.
+// It returns false if the client doesn't accept any of them.
+func (c *Compression) negotiate(header string) (Encoder, bool) {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				q, _ = strconv.ParseFloat(v[2:], 64)
+			}
+		}
+		accepted[name] = q
+	}
+
+	var best Encoder
+	var bestQ float64
+	for _, e := range c.encoders() {
+		q, ok := accepted[e.Name]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// compress compresses the buffered response in place, if it should be.
+func (c *Compression) compress(rw *responseStaller, req *http.Request) {
+	h := rw.Header()
+	if h.Get("Content-Encoding") != "" || rw.code == http.StatusNoContent || rw.code == http.StatusNotModified {
+		return
+	}
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(rw.buf.Bytes())
+	}
+	if !c.compressible(contentType) {
+		return
+	}
+
+	// The response depends on Accept-Encoding from here on, even if it
+	// ends up not being compressed.
+	addVary(h, "Accept-Encoding")
+
+	if rw.buf.Len() < c.minSize() {
+		return
+	}
+	e, ok := c.negotiate(req.Header.Get("Accept-Encoding"))
+	if !ok {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	zw, err := e.New(buf)
+	if err != nil {
+		return
+	}
+	if _, err := zw.Write(rw.buf.Bytes()); err != nil {
+		return
+	}
+	if err := zw.Close(); err != nil {
+		return
+	}
+
+	// Keep the original when compression doesn't pay off.
+	if buf.Len() >= rw.buf.Len() {
+		return
+	}
+
+	// A strong ETag belongs to the uncompressed bytes.
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	h.Set("Content-Encoding", e.Name)
+	h.Del("Content-Length")
+	rw.buf = buf
+}
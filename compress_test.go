@@ -0,0 +1,218 @@
+package turbo_test
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestHandlerWithOptions_Compression(t *testing.T) {
+	t.Parallel()
+
+	page := "<p>" + strings.Repeat("turbo ", 500) + "</p>"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", turbo.ContentHTML)
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, page)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<p>hi</p>")
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, page)
+	})
+	mux.HandleFunc("/encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", turbo.ContentHTML)
+		w.Header().Set("Content-Encoding", "br")
+		io.WriteString(w, page)
+	})
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/users/1", http.StatusFound)
+	})
+
+	h := turbo.HandlerWithOptions(mux, turbo.HandlerOptions{
+		Compression: &turbo.Compression{},
+	})
+
+	decode := func(t *testing.T, res *httptest.ResponseRecorder) string {
+		t.Helper()
+
+		var r io.Reader = res.Body
+		switch res.Header().Get("Content-Encoding") {
+		case "gzip":
+			zr, err := gzip.NewReader(res.Body)
+			if err != nil {
+				t.Fatalf("unexpected error reading gzip: %v", err)
+			}
+			r = zr
+		case "deflate":
+			r = flate.NewReader(res.Body)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("unexpected error decoding body: %v", err)
+		}
+		return string(b)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		turbolinks     bool
+		acceptEncoding string
+		encoding       string
+		vary           bool
+	}{
+		{"gzip", http.MethodGet, "/page", false, "gzip, deflate", "gzip", true},
+		{"Turbolinks visit", http.MethodGet, "/page", true, "gzip", "gzip", true},
+		{"preferred by q-value", http.MethodGet, "/page", false, "gzip;q=0.5, deflate", "deflate", true},
+		{"wildcard", http.MethodGet, "/page", false, "*", "gzip", true},
+		{"refused", http.MethodGet, "/page", false, "gzip;q=0, identity", "", true},
+		{"not accepted", http.MethodGet, "/page", false, "", "", true},
+		{"below minimum size", http.MethodGet, "/small", false, "gzip", "", true},
+		{"content type not allowed", http.MethodGet, "/image", false, "gzip", "", false},
+		{"already encoded", http.MethodGet, "/encoded", false, "gzip", "br", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.turbolinks {
+				req.Header.Set(turbo.TurbolinksReferrer, "http://example.com/")
+			}
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			h.ServeHTTP(res, req)
+
+			if res.Code != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d", res.Code)
+			}
+			if encoding := res.Header().Get("Content-Encoding"); encoding != tt.encoding {
+				t.Fatalf("expected Content-Encoding %q but got %q", tt.encoding, encoding)
+			}
+			if vary := res.Header().Get("Vary") == "Accept-Encoding"; vary != tt.vary {
+				t.Fatalf("expected Vary to be set to be %t but got %q", tt.vary, res.Header().Get("Vary"))
+			}
+			if tt.encoding == "gzip" || tt.encoding == "deflate" {
+				if body := decode(t, res); body != page {
+					t.Fatalf("expected decompressed body to be the page but got %s", body)
+				}
+				if etag := res.Header().Get("ETag"); etag != `W/"v1"` {
+					t.Fatalf("expected ETag to be weakened but got %s", etag)
+				}
+			}
+		})
+	}
+
+	t.Run("redirect after form submission", func(t *testing.T) {
+		h := turbo.HandlerWithOptions(mux, turbo.HandlerOptions{
+			Compression: &turbo.Compression{MinSize: 1},
+		})
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users", nil)
+		req.Header.Set(turbo.TurbolinksReferrer, "http://example.com/users/new")
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d", res.Code)
+		}
+		if contentType := res.Header().Get("Content-Type"); contentType != "text/javascript" {
+			t.Fatalf("expected Content-Type text/javascript but got %s", contentType)
+		}
+		expected := `Turbolinks.clearCache();Turbolinks.visit("/users/1", {action: "advance"});`
+		if body := decode(t, res); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+
+	t.Run("flushed responses are streamed", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", turbo.ContentHTML)
+			io.WriteString(w, page)
+			w.(http.Flusher).Flush()
+			io.WriteString(w, page)
+		})
+		h := turbo.HandlerWithOptions(mux, turbo.HandlerOptions{
+			Compression: &turbo.Compression{},
+		})
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(res, req)
+
+		if !res.Flushed {
+			t.Fatalf("expected response to be flushed")
+		}
+		if encoding := res.Header().Get("Content-Encoding"); encoding != "" {
+			t.Fatalf("expected streamed response not to be compressed but got %s", encoding)
+		}
+		if body := res.Body.String(); body != page+page {
+			t.Fatalf("expected the whole page but got %d bytes", len(body))
+		}
+	})
+
+	t.Run("event streams are not buffered", func(t *testing.T) {
+		next := make(chan struct{})
+		srv := httptest.NewServer(turbo.HandlerWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+strings.Repeat("a", 2048)+"\n\n")
+			w.(http.Flusher).Flush()
+
+			// The second event is only sent once the client has read the
+			// first, which it never would if the response were buffered.
+			<-next
+			io.WriteString(w, "data: b\n\n")
+		}), turbo.HandlerOptions{
+			Compression: &turbo.Compression{},
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			close(next)
+			t.Fatalf("unexpected error performing request: %v", err)
+		}
+		defer res.Body.Close()
+
+		if encoding := res.Header.Get("Content-Encoding"); encoding != "" {
+			close(next)
+			t.Fatalf("expected event stream not to be compressed but got %s", encoding)
+		}
+
+		events := bufio.NewReader(res.Body)
+		first, err := events.ReadString('\n')
+		close(next)
+		if err != nil || !strings.HasPrefix(first, "data: aaa") {
+			t.Fatalf("expected first event but got %q: %v", first, err)
+		}
+
+		rest, _ := io.ReadAll(events)
+		if expected := "\ndata: b\n\n"; string(rest) != expected {
+			t.Fatalf("expected %q but got %q", expected, rest)
+		}
+	})
+}
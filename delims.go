@@ -0,0 +1,37 @@
+package turbo
+
+import "strings"
+
+// Delims are the delimiters of the actions in a template. Either one
+// defaults to DefaultLeftDelim or DefaultRightDelim when it is empty.
+type Delims struct {
+	Left  string
+	Right string
+}
+
+// delims returns the delimiters of the template with the given name. The
+// override for the deepest directory that contains the template wins over
+// Options.Delims.
+func (r *Render) delims(name string) (string, string) {
+	d := r.opt.Delims
+
+	depth := -1
+	for dir, override := range r.opt.DirDelims {
+		dir = strings.Trim(dir, "/")
+		if dir != "" && !strings.HasPrefix(name, dir+"/") {
+			continue
+		}
+		if len(dir) > depth {
+			d, depth = override, len(dir)
+		}
+	}
+
+	left, right := d.Left, d.Right
+	if left == "" {
+		left = DefaultLeftDelim
+	}
+	if right == "" {
+		right = DefaultRightDelim
+	}
+	return left, right
+}
@@ -0,0 +1,60 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Delims(t *testing.T) {
+	t.Parallel()
+
+	opts := turbo.Options{
+		Directory: "fixtures/delims",
+		Layout:    "layout",
+		Delims:    turbo.Delims{Left: "[[", Right: "]]"},
+		DirDelims: map[string]turbo.Delims{
+			"legacy": {},
+		},
+	}
+
+	const expected = `<main><div id="app">{{ message }}</div><b>new</b></main>`
+
+	tests := []struct {
+		name   string
+		minify *turbo.Minify
+	}{
+		{"compile", nil},
+		{"minify precompile", &turbo.Minify{Precompile: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := opts
+			opts.Minify = tt.minify
+			render := turbo.New(opts)
+
+			res := httptest.NewRecorder()
+			if err := render.HTML(res, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "content", "new"); err != nil {
+				t.Fatalf("unexpected error rendering template: %v", err)
+			}
+			if body := res.Body.String(); body != expected {
+				t.Fatalf("expected %s but got %s", expected, body)
+			}
+		})
+	}
+
+	t.Run("lint", func(t *testing.T) {
+		if errs := turbo.Lint(opts); len(errs) != 0 {
+			t.Fatalf("expected no problems but got %v", errs)
+		}
+
+		// With the default delimiters, {{ message }} calls an undefined
+		// function.
+		if errs := turbo.Lint(turbo.Options{Directory: "fixtures/delims"}); len(errs) == 0 {
+			t.Fatalf("expected problems with the default delimiters")
+		}
+	})
+}
@@ -0,0 +1,384 @@
+package turbo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// The suffixes of the names of the templates of the HTML and plain text
+// parts of an email, ie, welcome.html.tmpl and welcome.text.tmpl.
+const (
+	emailHTML = ".html"
+	emailText = ".text"
+)
+
+// isEmailText reports whether the template with the given name is the plain
+// text part of an email.
+func isEmailText(name string) bool {
+	return strings.HasSuffix(name, emailText)
+}
+
+// Email is an email rendered by Render.Email.
+type Email struct {
+	// Subject is taken from the subject key of the front matter of the
+	// templates, if they have any.
+	Subject string
+
+	// Header holds the other headers of the message, like From and To.
+	Header textproto.MIMEHeader
+
+	// HTML and Text are the rendered parts of the email. Either one is
+	// empty if its template doesn't exist.
+	HTML string
+	Text string
+}
+
+// Email renders the email with the given name from the name.html and
+// name.text templates, in the name.html and name.text variants of
+// Options.EmailLayout when they exist. At least one of the two templates
+// must exist.
+func (r *Render) Email(name string, binding interface{}) (*Email, error) {
+	// If we're in development mode, recompile the templates.
+	if r.opt.IsDevelopment {
+		r.reloadAssets()
+		r.compileTemplatesFromDir()
+	}
+
+	rc, err := r.newRender(context.Background(), "")
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(rc)
+
+	htmlName, textName := name+emailHTML, name+emailText
+	texts := r.set("").texts
+	hasHTML, hasText := rc.templates.Lookup(htmlName) != nil, texts.Lookup(textName) != nil
+	if !hasHTML && !hasText {
+		return nil, fmt.Errorf("turbo: no templates for email %q", name)
+	}
+
+	e := &Email{Header: textproto.MIMEHeader{}}
+	if hasHTML {
+		if e.HTML, err = r.emailHTML(rc, htmlName, binding); err != nil {
+			return nil, err
+		}
+	}
+	if hasText {
+		if e.Text, err = r.emailText(rc, texts, textName, binding); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, n := range []string{htmlName, textName} {
+		if subject, ok := rc.meta[n]["subject"].(string); ok && e.Subject == "" {
+			e.Subject = subject
+		}
+	}
+	return e, nil
+}
+
+// emailHTML renders the HTML part of an email.
+func (r *Render) emailHTML(rc *renderContext, name string, binding interface{}) (string, error) {
+	r.addRequestFuncs(placeholderWriter{}, placeholderRequest(), rc, name, binding, true)
+
+	var buf *bytes.Buffer
+	var err error
+	if layout := r.opt.EmailLayout + emailHTML; r.opt.EmailLayout != "" && rc.templates.Lookup(layout) != nil {
+		rc.templates.Funcs(template.FuncMap{
+			"yield": func() (template.HTML, error) {
+				buf, err := r.execute(rc, RenderYield, name, binding)
+				return template.HTML(buf.String()), err
+			},
+		})
+		buf = &bytes.Buffer{}
+		err = r.instrument(rc, RenderPage, name, layout, func() (int, error) {
+			err := rc.templates.ExecuteTemplate(&ctxWriter{rc: rc, w: buf}, layout, binding)
+			return buf.Len(), err
+		})
+	} else {
+		buf, err = r.execute(rc, RenderPartial, name, binding)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if r.opt.EmailInlineCSS {
+		return inlineCSS(buf.String()), nil
+	}
+	return buf.String(), nil
+}
+
+// emailText renders the plain text part of an email, with the same helpers
+// as the HTML part.
+func (r *Render) emailText(rc *renderContext, texts *texttemplate.Template, name string, binding interface{}) (string, error) {
+	// Plain text templates can be executed any number of times and still
+	// be cloned, but the funcs bound here must not leak into other renders.
+	tmpl, err := texts.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	execute := func(kind, name string, binding interface{}) (*bytes.Buffer, error) {
+		buf := &bytes.Buffer{}
+		return buf, r.instrument(rc, kind, name, "", func() (int, error) {
+			err := tmpl.ExecuteTemplate(&ctxWriter{rc: rc, w: buf}, name, binding)
+			return buf.Len(), err
+		})
+	}
+
+	for _, funcs := range r.requestFuncs(placeholderWriter{}, placeholderRequest(), rc, name, binding, true) {
+		tmpl.Funcs(texttemplate.FuncMap(funcs))
+	}
+
+	// The helpers that render other templates have to render them as text.
+	tmpl.Funcs(texttemplate.FuncMap{
+		"yield": func() (string, error) {
+			buf, err := execute(RenderYield, name, binding)
+			return buf.String(), err
+		},
+		"partial": func(name string, binding interface{}) (string, error) {
+			buf, err := execute(RenderPartial, name, binding)
+			return buf.String(), err
+		},
+		"cache": func(name string, binding interface{}, keys ...interface{}) (string, error) {
+			text, err := r.cached(rc, name, keys, func() (*bytes.Buffer, error) {
+				return execute(RenderPartial, name, binding)
+			})
+			return string(text), err
+		},
+	})
+
+	if layout := r.opt.EmailLayout + emailText; r.opt.EmailLayout != "" && tmpl.Lookup(layout) != nil {
+		buf := &bytes.Buffer{}
+		err := r.instrument(rc, RenderPage, name, layout, func() (int, error) {
+			err := tmpl.ExecuteTemplate(&ctxWriter{rc: rc, w: buf}, layout, binding)
+			return buf.Len(), err
+		})
+		return buf.String(), err
+	}
+	buf, err := execute(RenderPartial, name, binding)
+	return buf.String(), err
+}
+
+// WriteTo writes the email as a MIME message. Emails with both parts are
+// sent as multipart/alternative, with the plain text part first. Nothing is
+// written if a header is invalid.
+func (e *Email) WriteTo(w io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+
+	h := textproto.MIMEHeader{}
+	for k, v := range e.Header {
+		h[k] = v
+	}
+	if e.Subject != "" && h.Get("Subject") == "" {
+		h.Set("Subject", e.Subject)
+	}
+	h.Set("MIME-Version", "1.0")
+
+	if e.HTML != "" && e.Text != "" {
+		mw := multipart.NewWriter(buf)
+		h.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+		if err := writeMIMEHeader(buf, h); err != nil {
+			return 0, err
+		}
+
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", e.Text},
+			{"text/html; charset=utf-8", e.HTML},
+		} {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return 0, err
+			}
+			if err := writeQuotedPrintable(pw, part.body); err != nil {
+				return 0, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return 0, err
+		}
+	} else {
+		contentType, body := "text/plain; charset=utf-8", e.Text
+		if e.HTML != "" {
+			contentType, body = "text/html; charset=utf-8", e.HTML
+		}
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		if err := writeMIMEHeader(buf, h); err != nil {
+			return 0, err
+		}
+		if err := writeQuotedPrintable(buf, body); err != nil {
+			return 0, err
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+// Bytes returns the email as a MIME message.
+func (e *Email) Bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	_, err := e.WriteTo(buf)
+	return buf.Bytes(), err
+}
+
+// addressHeaders are the headers that hold a list of addresses.
+var addressHeaders = map[string]bool{
+	"From":     true,
+	"To":       true,
+	"Cc":       true,
+	"Bcc":      true,
+	"Reply-To": true,
+	"Sender":   true,
+}
+
+// writeMIMEHeader writes the header, in a stable order, followed by the
+// blank line that ends it. Values are encoded as RFC 2047 encoded-words when
+// they aren't ASCII, and addresses are reformatted, so that names are
+// encoded too. Keys or values with line breaks are rejected, since they
+// would add headers of their own.
+func writeMIMEHeader(w io.Writer, h textproto.MIMEHeader) error {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if strings.ContainsAny(k, "\r\n") {
+			return fmt.Errorf("turbo: invalid email header %q", k)
+		}
+		for _, v := range h[k] {
+			if strings.ContainsAny(v, "\r\n") {
+				return fmt.Errorf("turbo: invalid value for email header %s", k)
+			}
+
+			if addressHeaders[textproto.CanonicalMIMEHeaderKey(k)] {
+				addrs, err := mail.ParseAddressList(v)
+				if err != nil {
+					return fmt.Errorf("turbo: invalid addresses for email header %s: %v", k, err)
+				}
+				list := make([]string, len(addrs))
+				for i, addr := range addrs {
+					list[i] = addr.String()
+				}
+				v = strings.Join(list, ", ")
+			} else {
+				v = mime.QEncoding.Encode("utf-8", v)
+			}
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qw, body); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// emails returns the names of the emails that have templates.
+func (r *Render) emails() []string {
+	set := r.set("")
+	seen := make(map[string]bool)
+
+	add := func(name, suffix string) {
+		if base := strings.TrimSuffix(name, suffix); base != name && base != r.opt.EmailLayout {
+			seen[base] = true
+		}
+	}
+	for _, t := range set.templates.Templates() {
+		add(t.Name(), emailHTML)
+	}
+	for _, t := range set.texts.Templates() {
+		add(t.Name(), emailText)
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var emailIndex = template.Must(template.New("emails").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Emails</title></head>
+<body>
+<h1>Emails</h1>
+<ul>
+{{- range . }}
+<li><a href="{{ . }}">{{ . }}</a> (<a href="{{ . }}?part=text">text</a>, <a href="{{ . }}?part=raw">raw</a>)</li>
+{{- end }}
+</ul>
+</body>
+</html>
+`))
+
+// EmailPreview returns a handler that lists every email, and renders each
+// one with its sample data. It is meant to be mounted in development:
+//
+//	mux.Handle("/_emails/", http.StripPrefix("/_emails/", render.EmailPreview(samples)))
+//
+// The sample for an email is looked up by its name. Samples that are a
+// func() interface{} are called for every preview, so they can build fresh
+// data. The HTML part is shown by default, and the part query parameter
+// picks the text part or the raw message instead.
+func (r *Render) EmailPreview(samples map[string]interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := strings.Trim(req.URL.Path, "/")
+		if name == "" {
+			w.Header().Set("Content-Type", ContentHTML)
+			emailIndex.Execute(w, r.emails())
+			return
+		}
+
+		binding := samples[name]
+		if fn, ok := binding.(func() interface{}); ok {
+			binding = fn()
+		}
+
+		e, err := r.Email(name, binding)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		switch part := req.URL.Query().Get("part"); {
+		case part == "raw":
+			b, err := e.Bytes()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", ContentText)
+			w.Write(b)
+		case part == "text" || e.HTML == "":
+			w.Header().Set("Content-Type", ContentText)
+			io.WriteString(w, e.Text)
+		default:
+			w.Header().Set("Content-Type", ContentHTML)
+			io.WriteString(w, e.HTML)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package turbo_test
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bentranter/turbo"
+)
+
+type emailUser struct {
+	Name string
+}
+
+func newEmailRender(inline bool) *turbo.Render {
+	return turbo.New(turbo.Options{
+		Directory:      "fixtures/email",
+		EmailLayout:    "emails/layout",
+		EmailInlineCSS: inline,
+		Processors:     map[string][]turbo.Processor{".tmpl": {turbo.FrontMatter}},
+	})
+}
+
+func TestRender_Email(t *testing.T) {
+	t.Parallel()
+
+	render := newEmailRender(true)
+
+	e, err := render.Email("emails/welcome", emailUser{Name: "Ben & <Co>"})
+	if err != nil {
+		t.Fatalf("unexpected error rendering email: %v", err)
+	}
+
+	if e.Subject != "Welcome, friend" {
+		t.Fatalf("expected subject from front matter but got %q", e.Subject)
+	}
+	if expected := "Hi Ben & <Co>, you're in.\n\n--\nAcme\n"; e.Text != expected {
+		t.Fatalf("expected text part to be unescaped\n%q\nbut got\n%q", expected, e.Text)
+	}
+
+	for _, expected := range []string{
+		`<p style="color: #333; margin: 0">Hi Ben &amp; &lt;Co&gt;</p>`,
+		`<a class="button" href="/start" style="background: blue; color: white">Start</a>`,
+		`<p id="footer" style="color: #333; font-size: 12px">Acme</p>`,
+		`<style>a:hover{ color: red; }@media (max-width: 600px){ p { color: black; } }</style>`,
+	} {
+		if !strings.Contains(e.HTML, expected) {
+			t.Fatalf("expected HTML part to contain\n%s\nbut got\n%s", expected, e.HTML)
+		}
+	}
+
+	t.Run("MIME message", func(t *testing.T) {
+		e.Header.Set("To", "Bén <ben@example.com>, ana@example.com")
+		e.Header.Set("X-Campaign", "Bienvenue à bord")
+		b, err := e.Bytes()
+		if err != nil {
+			t.Fatalf("unexpected error writing message: %v", err)
+		}
+
+		msg, err := mail.ReadMessage(strings.NewReader(string(b)))
+		if err != nil {
+			t.Fatalf("unexpected error reading message: %v", err)
+		}
+		to, err := msg.Header.AddressList("To")
+		if err != nil || len(to) != 2 || to[0].Name != "Bén" || to[0].Address != "ben@example.com" || to[1].Address != "ana@example.com" {
+			t.Fatalf("expected To header to be encoded but got %q", msg.Header.Get("To"))
+		}
+		campaign, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("X-Campaign"))
+		if raw := msg.Header.Get("X-Campaign"); campaign != "Bienvenue à bord" || !strings.HasPrefix(raw, "=?utf-8?q?") {
+			t.Fatalf("expected X-Campaign header to be encoded but got %q", raw)
+		}
+		subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		if subject != "Welcome, friend" {
+			t.Fatalf("expected Subject header but got %q", subject)
+		}
+
+		mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/alternative" {
+			t.Fatalf("expected multipart/alternative but got %q", msg.Header.Get("Content-Type"))
+		}
+
+		mr := multipart.NewReader(msg.Body, params["boundary"])
+		for _, expected := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", e.Text},
+			{"text/html; charset=utf-8", e.HTML},
+		} {
+			part, err := mr.NextPart()
+			if err != nil {
+				t.Fatalf("unexpected error reading part: %v", err)
+			}
+			if contentType := part.Header.Get("Content-Type"); contentType != expected.contentType {
+				t.Fatalf("expected part %s but got %s", expected.contentType, contentType)
+			}
+
+			// The quoted-printable encoding is decoded by the reader, but
+			// line breaks are sent as CRLF.
+			body, _ := io.ReadAll(part)
+			if strings.ReplaceAll(string(body), "\r\n", "\n") != expected.body {
+				t.Fatalf("expected part body\n%q\nbut got\n%q", expected.body, body)
+			}
+		}
+	})
+
+	t.Run("header injection", func(t *testing.T) {
+		for _, h := range []struct{ key, value string }{
+			{"Subject", "Hi\r\nBcc: eve@example.com"},
+			{"X-Note\r\nBcc", "eve@example.com"},
+			{"To", "ben@example.com\nBcc: eve@example.com"},
+		} {
+			e := &turbo.Email{Text: "hi", Header: map[string][]string{h.key: {h.value}}}
+			if b, err := e.Bytes(); err == nil || len(b) != 0 {
+				t.Fatalf("expected header %q: %q to be rejected but got %q", h.key, h.value, b)
+			}
+		}
+
+		e := &turbo.Email{Text: "hi", Header: map[string][]string{"To": {"not an address"}}}
+		if _, err := e.Bytes(); err == nil {
+			t.Fatalf("expected invalid address to be rejected")
+		}
+	})
+
+	t.Run("text only", func(t *testing.T) {
+		e, err := render.Email("emails/receipt", emailUser{Name: "Ben"})
+		if err != nil {
+			t.Fatalf("unexpected error rendering email: %v", err)
+		}
+		if e.HTML != "" || e.Text != "Receipt for Ben\n--\nAcme\n" {
+			t.Fatalf("expected only a text part but got %q and %q", e.HTML, e.Text)
+		}
+
+		b, _ := e.Bytes()
+		msg, err := mail.ReadMessage(strings.NewReader(string(b)))
+		if err != nil {
+			t.Fatalf("unexpected error reading message: %v", err)
+		}
+		if contentType := msg.Header.Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+			t.Fatalf("expected a plain text message but got %s", contentType)
+		}
+
+		// Plain text parts aren't HTML, so they can't be rendered as such.
+		if _, err := render.String(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "emails/receipt.text", nil); err == nil {
+			t.Fatalf("expected plain text part not to be parsed as HTML")
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, err := render.Email("emails/missing", nil); err == nil {
+			t.Fatalf("expected error for missing email")
+		}
+	})
+
+	t.Run("without inlining", func(t *testing.T) {
+		e, err := newEmailRender(false).Email("emails/welcome", emailUser{Name: "Ben"})
+		if err != nil {
+			t.Fatalf("unexpected error rendering email: %v", err)
+		}
+		if !strings.Contains(e.HTML, `<p style="margin: 0">Hi Ben</p>`) || !strings.Contains(e.HTML, "#footer { font-size: 12px; }") {
+			t.Fatalf("expected styles to be left alone but got %s", e.HTML)
+		}
+	})
+}
+
+func TestRender_EmailPreview(t *testing.T) {
+	t.Parallel()
+
+	render := newEmailRender(false)
+	h := http.StripPrefix("/_emails/", render.EmailPreview(map[string]interface{}{
+		"emails/welcome": emailUser{Name: "Sample"},
+		"emails/receipt": func() interface{} { return emailUser{Name: "Fresh"} },
+	}))
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{"/_emails/", http.StatusOK, turbo.ContentHTML, `<a href="emails/receipt">emails/receipt</a>`},
+		{"/_emails/emails/welcome", http.StatusOK, turbo.ContentHTML, "Hi Sample"},
+		{"/_emails/emails/welcome?part=text", http.StatusOK, turbo.ContentText, "Hi Sample, you're in."},
+		{"/_emails/emails/welcome?part=raw", http.StatusOK, turbo.ContentText, "multipart/alternative"},
+		{"/_emails/emails/receipt", http.StatusOK, turbo.ContentText, "Receipt for Fresh"},
+		{"/_emails/emails/missing", http.StatusNotFound, "", "no templates"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if res.Code != tt.status {
+				t.Fatalf("expected status %d but got %d", tt.status, res.Code)
+			}
+			if tt.contentType != "" && res.Header().Get("Content-Type") != tt.contentType {
+				t.Fatalf("expected Content-Type %s but got %s", tt.contentType, res.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(res.Body.String(), tt.contains) {
+				t.Fatalf("expected body to contain %s but got %s", tt.contains, res.Body.String())
+			}
+		})
+	}
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/_emails/", nil))
+	if strings.Contains(res.Body.String(), `href="emails/layout"`) {
+		t.Fatalf("expected the layout not to be listed but got %s", res.Body.String())
+	}
+}
+
+func TestRender_EmailHelpers(t *testing.T) {
+	t.Setenv(turbo.RevisionEnv, "abc")
+
+	i18n, err := turbo.NewI18n(os.DirFS("fixtures/i18n/locales"), "fr")
+	if err != nil {
+		t.Fatalf("unexpected error loading catalogs: %v", err)
+	}
+	render := turbo.New(turbo.Options{
+		Roots: []fs.FS{
+			fstest.MapFS{
+				"emails/hi.html.tmpl":   {Data: []byte(`<p>{{ revision }} {{ t "title" }} {{ cache "emails/_sig.html" . }}</p>`)},
+				"emails/hi.text.tmpl":   {Data: []byte(`{{ revision }} {{ t "title" }} {{ cache "emails/_sig.text" . }}`)},
+				"emails/_sig.html.tmpl": {Data: []byte(`<i>{{ . }}</i>`)},
+				"emails/_sig.text.tmpl": {Data: []byte(`-- {{ . }}`)},
+			},
+		},
+		I18n:  i18n,
+		Cache: turbo.NewLRU(10),
+	})
+
+	// The second render is served from the cache.
+	for i := 0; i < 2; i++ {
+		e, err := render.Email("emails/hi", "Ben")
+		if err != nil {
+			t.Fatalf("unexpected error rendering email: %v", err)
+		}
+		if expected := "<p>abc Utilisateurs <i>Ben</i></p>"; e.HTML != expected {
+			t.Fatalf("expected HTML part %s but got %s", expected, e.HTML)
+		}
+		if expected := "abc Utilisateurs -- Ben"; e.Text != expected {
+			t.Fatalf("expected text part %s but got %s", expected, e.Text)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package turbo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NotModified reports whether the client's cached copy is still fresh,
+// according to the ETag and Last-Modified headers already set on the
+// response, and answers with 304 Not Modified if it is. Handlers can call it
+// before rendering to skip the render altogether.
+//
+//	w.Header().Set("Last-Modified", post.UpdatedAt.UTC().Format(http.TimeFormat))
+//	if turbo.NotModified(w, r) {
+//		return
+//	}
+func NotModified(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if !fresh(w.Header(), req.Header) {
+		return false
+	}
+
+	// A 304 has no body, so the headers that describe it are left out.
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// fresh checks the conditional request headers against the validators in
+// the response headers. If-None-Match takes precedence over
+// If-Modified-Since, as in RFC 7232.
+func fresh(res, req http.Header) bool {
+	if inm := req.Get("If-None-Match"); inm != "" {
+		etag := res.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakMatch(candidate, etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(req.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(res.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(ims)
+}
+
+// weakMatch compares two entity tags with the weak comparison function,
+// which is the one used for If-None-Match.
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// strongETag returns a strong entity tag for the given body.
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,89 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_ETags(t *testing.T) {
+	t.Parallel()
+
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/basic",
+		Layout:    "layout",
+		ETags:     true,
+	})
+
+	modified := time.Date(2018, time.March, 4, 12, 0, 0, 0, time.UTC)
+
+	serve := func(method string, header http.Header, lastModified bool) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header = header
+		if lastModified {
+			res.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		}
+		render.HTML(res, req, http.StatusOK, "content", "hi")
+		return res
+	}
+
+	first := serve(http.MethodGet, http.Header{}, false)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag but got %d with %q", first.Code, etag)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		header       http.Header
+		lastModified bool
+		expected     int
+	}{
+		{"matching ETag", http.MethodGet, http.Header{"If-None-Match": {etag}}, false, http.StatusNotModified},
+		{"weak matching ETag", http.MethodGet, http.Header{"If-None-Match": {`"abc", W/` + etag}}, false, http.StatusNotModified},
+		{"wildcard", http.MethodGet, http.Header{"If-None-Match": {"*"}}, false, http.StatusNotModified},
+		{"changed ETag", http.MethodGet, http.Header{"If-None-Match": {`"abc"`}}, false, http.StatusOK},
+		{"HEAD", http.MethodHead, http.Header{"If-None-Match": {etag}}, false, http.StatusNotModified},
+		{"POST", http.MethodPost, http.Header{"If-None-Match": {etag}}, false, http.StatusOK},
+		{"not modified since", http.MethodGet, http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}}, true, http.StatusNotModified},
+		{"modified since", http.MethodGet, http.Header{"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}}, true, http.StatusOK},
+		{"If-None-Match takes precedence", http.MethodGet, http.Header{
+			"If-None-Match":     {`"abc"`},
+			"If-Modified-Since": {modified.Format(http.TimeFormat)},
+		}, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(tt.method, tt.header, tt.lastModified)
+			if res.Code != tt.expected {
+				t.Fatalf("expected status %d but got %d", tt.expected, res.Code)
+			}
+			if tt.expected == http.StatusNotModified && res.Body.Len() != 0 {
+				t.Fatalf("expected 304 to have no body but got %s", res.Body.String())
+			}
+			if tt.lastModified && res.Header().Get("ETag") != "" {
+				t.Fatalf("expected no ETag when the handler set Last-Modified")
+			}
+		})
+	}
+
+	t.Run("handler ETag", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		res.Header().Set("ETag", `W/"v1"`)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-None-Match", `W/"v1"`)
+
+		if !turbo.NotModified(res, req) {
+			t.Fatalf("expected handler ETag to match")
+		}
+		if res.Code != http.StatusNotModified {
+			t.Fatalf("expected status 304 but got %d", res.Code)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package turbo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// flashVersion prefixes structured flash cookies. It can't appear in a
+// legacy flash, since "." isn't in the URL-safe base64 alphabet.
+const flashVersion = "1."
+
+// FlashMessage is the payload of a flash cookie. It holds either a message
+// that has already been translated, or a key and arguments to translate when
+// the flash is read.
+type FlashMessage struct {
+	Message string        `json:"m,omitempty"`
+	Key     string        `json:"k,omitempty"`
+	Args    []interface{} `json:"a,omitempty"`
+}
+
+// String returns the message, or the key if the flash needs translating.
+func (f *FlashMessage) String() string {
+	if f.Key != "" {
+		return f.Key
+	}
+	return f.Message
+}
+
+// encode returns the flash as a cookie value.
+func (f *FlashMessage) encode() string {
+	b, err := json.Marshal(f)
+	if err != nil {
+		// The args can't be encoded, so fall back to the bare key.
+		b, _ = json.Marshal(&FlashMessage{Message: f.Message, Key: f.Key})
+	}
+	return flashVersion + base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeFlash decodes the value of a flash cookie. Cookies set by older
+// versions, which hold a base64 encoded message, are still understood.
+func DecodeFlash(value string) (*FlashMessage, bool) {
+	if !strings.HasPrefix(value, flashVersion) {
+		message, err := base64.URLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, false
+		}
+		return &FlashMessage{Message: string(message)}, true
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, flashVersion))
+	if err != nil {
+		return nil, false
+	}
+
+	// Numbers are kept as they were written, rather than as float64, so
+	// that large counts aren't formatted like 1e+06.
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	f := &FlashMessage{}
+	if err := d.Decode(f); err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// FlashKey sets a flash message on the given response that is translated
+// with Options.I18n when it is read, in the locale of the request that
+// displays it. The args are passed to I18n.Translate, so they need to
+// survive being encoded as JSON.
+func (r *Render) FlashKey(w http.ResponseWriter, key string, args ...interface{}) {
+	r.setFlash(w, &FlashMessage{Key: key, Args: args})
+}
+
+// RedirectKey redirects the user to the given URL, and sets a flash message
+// that is translated when it is displayed, as with FlashKey.
+func (r *Render) RedirectKey(w http.ResponseWriter, req *http.Request, url string, key string, args ...interface{}) {
+	r.FlashKey(w, key, args...)
+	http.Redirect(w, req, url, http.StatusFound)
+}
+
+func (r *Render) setFlash(w http.ResponseWriter, f *FlashMessage) {
+	http.SetCookie(w, &http.Cookie{
+		Name:  DefaultFlashCookieName,
+		Value: f.encode(),
+	})
+}
+
+// translateFlash returns the message to display for the flash, translating
+// it in the locale of the request if needed.
+func (r *Render) translateFlash(req *http.Request, f *FlashMessage) string {
+	if f.Key == "" {
+		return f.Message
+	}
+	if r.opt.I18n == nil {
+		return f.Key
+	}
+	return r.opt.I18n.Translate(r.opt.I18n.Detect(req), f.Key, f.Args...)
+}
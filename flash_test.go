@@ -0,0 +1,93 @@
+package turbo_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_FlashKey(t *testing.T) {
+	t.Parallel()
+
+	i18n, err := turbo.NewI18n(os.DirFS("fixtures/i18n/locales"), "en")
+	if err != nil {
+		t.Fatalf("unexpected error loading catalogs: %v", err)
+	}
+
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/i18n/views",
+		Layout:    "layout",
+		I18n:      i18n,
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	render.RedirectKey(res, req, "/users/1", "users.created", "name", "Ben")
+
+	if location := res.Header().Get("Location"); location != "/users/1" {
+		t.Fatalf("expected redirect to /users/1 but got %s", location)
+	}
+	cookies := res.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected flash cookie to be set but got %v", cookies)
+	}
+
+	tests := []struct {
+		name     string
+		language string
+		expected string
+	}{
+		{"default locale", "", "Created Ben"},
+		{"locale of the displaying request", "fr", "Ben créé"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.AddCookie(cookies[0])
+			if tt.language != "" {
+				req.Header.Set("Accept-Language", tt.language)
+			}
+
+			if flash := render.GetFlash(httptest.NewRecorder(), req); flash != tt.expected {
+				t.Fatalf("expected flash %q but got %q", tt.expected, flash)
+			}
+		})
+	}
+
+	t.Run("numbers survive the cookie", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		render.FlashKey(res, "users.count", "count", 1000000)
+
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.AddCookie(res.Result().Cookies()[0])
+		if flash, expected := render.GetFlash(httptest.NewRecorder(), req), i18n.Translate("en", "users.count", "count", 1000000); flash != expected || flash != "1000000 users" {
+			t.Fatalf("expected flash %q but got %q", expected, flash)
+		}
+	})
+
+	t.Run("without i18n", func(t *testing.T) {
+		render := turbo.New(turbo.Options{Directory: "fixtures/basic"})
+
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.AddCookie(cookies[0])
+		if flash := render.GetFlash(httptest.NewRecorder(), req); flash != "users.created" {
+			t.Fatalf("expected flash to fall back to the key but got %q", flash)
+		}
+	})
+
+	t.Run("legacy cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.AddCookie(&http.Cookie{
+			Name:  turbo.DefaultFlashCookieName,
+			Value: base64.URLEncoding.EncodeToString([]byte("User created")),
+		})
+		if flash := render.GetFlash(httptest.NewRecorder(), req); flash != "User created" {
+			t.Fatalf("expected legacy flash to be decoded but got %q", flash)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package turbo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContentHTML is the Content-Type used for rendered HTML templates.
+	ContentHTML = "text/html; charset=utf-8"
+
+	// ContentJSON is the Content-Type used for JSON responses.
+	ContentJSON = "application/json; charset=utf-8"
+
+	// ContentXML is the Content-Type used for XML responses.
+	ContentXML = "application/xml; charset=utf-8"
+
+	// ContentText is the Content-Type used for plain text responses.
+	ContentText = "text/plain; charset=utf-8"
+)
+
+// Format is a response format that Respond is able to render.
+type Format string
+
+// The formats understood by Respond.
+const (
+	FormatHTML Format = "html"
+	FormatJSON Format = "json"
+	FormatXML  Format = "xml"
+	FormatText Format = "text"
+)
+
+// formatExtensions maps URL extensions to the format they request, so that
+// `/users/1.json` is answered with JSON regardless of the Accept header.
+var formatExtensions = map[string]Format{
+	".html": FormatHTML,
+	".json": FormatJSON,
+	".xml":  FormatXML,
+	".txt":  FormatText,
+}
+
+// formatMediaTypes maps media types found in the Accept header to the format
+// they request.
+var formatMediaTypes = map[string]Format{
+	"text/html":             FormatHTML,
+	"application/xhtml+xml": FormatHTML,
+	"application/json":      FormatJSON,
+	"text/json":             FormatJSON,
+	"application/xml":       FormatXML,
+	"text/xml":              FormatXML,
+	"text/plain":            FormatText,
+}
+
+// JSON renders the given value as JSON.
+//
+// The output is HTML-safe unless Options.UnEscapeHTML is set, indented if
+// Options.IndentJSON is set, and written directly to the response with a
+// streaming encoder if Options.StreamingJSON is set.
+func (r *Render) JSON(w http.ResponseWriter, status int, v interface{}) error {
+	if r.opt.StreamingJSON {
+		w.Header().Set("Content-Type", ContentJSON)
+		w.WriteHeader(status)
+		return r.newJSONEncoder(w).Encode(v)
+	}
+
+	// Encode to an intermediate buffer to check for errors.
+	buf := &bytes.Buffer{}
+	if err := r.newJSONEncoder(buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", ContentJSON)
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (r *Render) newJSONEncoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(!r.opt.UnEscapeHTML)
+	if r.opt.IndentJSON {
+		enc.SetIndent("", "  ")
+	}
+	return enc
+}
+
+// XML renders the given value as XML, prefixed with the standard XML header.
+func (r *Render) XML(w http.ResponseWriter, status int, v interface{}) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(buf)
+	if r.opt.IndentXML {
+		enc.Indent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", ContentXML)
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Text renders the given string as plain text.
+func (r *Render) Text(w http.ResponseWriter, status int, v string) error {
+	w.Header().Set("Content-Type", ContentText)
+	w.WriteHeader(status)
+	_, err := w.Write([]byte(v))
+	return err
+}
+
+// Respond renders the binding in the format requested by the client. The
+// format is taken from the URL extension if there is one, and otherwise
+// negotiated from the Accept header. HTML is used when neither asks for
+// anything we know how to render.
+//
+// For HTML, the template with the given name is rendered with its layout. For
+// plain text, the binding is formatted with fmt.Sprint.
+//
+// Since the response depends on the Accept header, Vary: Accept is added to
+// it, so that caches don't serve one format to a client that asked for
+// another.
+func (r *Render) Respond(w http.ResponseWriter, req *http.Request, status int, name string, binding interface{}) error {
+	addVary(w.Header(), "Accept")
+
+	switch NegotiateFormat(req) {
+	case FormatJSON:
+		return r.JSON(w, status, binding)
+	case FormatXML:
+		return r.XML(w, status, binding)
+	case FormatText:
+		return r.Text(w, status, fmt.Sprint(binding))
+	default:
+		return r.HTML(w, req, status, name, binding)
+	}
+}
+
+// NegotiateFormat returns the response format requested by the given request.
+func NegotiateFormat(req *http.Request) Format {
+	if f, ok := formatExtensions[strings.ToLower(path.Ext(req.URL.Path))]; ok {
+		return f
+	}
+
+	type accepted struct {
+		format Format
+		q      float64
+	}
+
+	// Collect every media type we understand along with its quality, and
+	// pick the one the client prefers the most. The sort is stable so that
+	// ties are won by whichever type the client listed first.
+	var formats []accepted
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		if f, ok := formatMediaTypes[mediaType]; ok {
+			formats = append(formats, accepted{format: f, q: q})
+		}
+	}
+	if len(formats) == 0 {
+		return FormatHTML
+	}
+
+	sort.SliceStable(formats, func(i, j int) bool {
+		return formats[i].q > formats[j].q
+	})
+	return formats[0].format
+}
@@ -0,0 +1,122 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_JSON(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/basic",
+	})
+
+	t.Run("render HTML-safe JSON", func(t *testing.T) {
+		const expected = `{"v":"\u003cb\u003e"}` + "\n"
+
+		res := httptest.NewRecorder()
+		if err := render.JSON(res, http.StatusCreated, map[string]string{"v": "<b>"}); err != nil {
+			t.Fatalf("unexpected error rendering JSON: %v", err)
+		}
+
+		if res.Code != http.StatusCreated {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusCreated, res.Code)
+		}
+		if contentType := res.Header().Get("Content-Type"); contentType != turbo.ContentJSON {
+			t.Fatalf("expected Content-Type to be %s but got %s", turbo.ContentJSON, contentType)
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+
+	t.Run("rendering invalid JSON should error", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		if err := render.JSON(res, http.StatusOK, make(chan int)); err == nil {
+			t.Fatalf("expected error rendering a channel as JSON but got none")
+		}
+
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusInternalServerError, res.Code)
+		}
+	})
+
+	t.Run("render indented unescaped JSON", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Directory:    "fixtures/basic",
+			IndentJSON:   true,
+			UnEscapeHTML: true,
+		})
+		const expected = "{\n  \"v\": \"<b>\"\n}\n"
+
+		res := httptest.NewRecorder()
+		if err := render.JSON(res, http.StatusOK, map[string]string{"v": "<b>"}); err != nil {
+			t.Fatalf("unexpected error rendering JSON: %v", err)
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+}
+
+func TestRender_Respond(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/basic",
+		Layout:    "layout",
+	})
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"default to HTML", "/", "", turbo.ContentHTML, `head<p>test</p>foot`},
+		{"browser Accept header", "/", "text/html,application/xhtml+xml,*/*;q=0.8", turbo.ContentHTML, `head<p>test</p>foot`},
+		{"JSON Accept header", "/", "application/json", turbo.ContentJSON, `"test"` + "\n"},
+		{"XML Accept header", "/", "application/xml", turbo.ContentXML, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<string>test</string>`},
+		{"text Accept header", "/", "text/plain", turbo.ContentText, `test`},
+		{"Accept header quality", "/", "text/html;q=0.5, application/json", turbo.ContentJSON, `"test"` + "\n"},
+		{"URL extension", "/users.json", "text/html", turbo.ContentJSON, `"test"` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			if err := render.Respond(res, req, http.StatusOK, "content", "test"); err != nil {
+				t.Fatalf("unexpected error responding: %v", err)
+			}
+
+			if contentType := res.Header().Get("Content-Type"); contentType != tt.contentType {
+				t.Fatalf("expected Content-Type to be %s but got %s", tt.contentType, contentType)
+			}
+			if body := res.Body.String(); body != tt.body {
+				t.Fatalf("expected %s but got %s", tt.body, body)
+			}
+			if vary := res.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept" {
+				t.Fatalf("expected Vary: Accept but got %v", vary)
+			}
+		})
+	}
+
+	t.Run("Vary already set", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		res.Header().Set("Vary", "Accept-Encoding, accept")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		if err := render.Respond(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error responding: %v", err)
+		}
+		if vary := res.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding, accept" {
+			t.Fatalf("expected Vary to be left alone but got %v", vary)
+		}
+	})
+}
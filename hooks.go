@@ -0,0 +1,345 @@
+package turbo
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The kinds of render that hooks are called for.
+const (
+	// RenderPage is a template rendered with its layout.
+	RenderPage = "page"
+
+	// RenderYield is a view rendered by yield, inside of a page.
+	RenderYield = "yield"
+
+	// RenderPartial is a template rendered without a layout, either by
+	// passing partial to HTML or String, or by the partial helper.
+	RenderPartial = "partial"
+
+	// RenderCache is a lookup in the fragment cache by the cache helper.
+	// On a miss, it is followed by the render of the partial.
+	RenderCache = "cache"
+)
+
+// RenderEvent describes a render, and is passed to the render hooks.
+type RenderEvent struct {
+	// Kind is one of RenderPage, RenderYield, RenderPartial or
+	// RenderCache.
+	Kind string
+
+	// Template is the name of the template being rendered.
+	Template string
+
+	// Layout is the name of the layout the template is rendered in. It is
+	// empty for partials.
+	Layout string
+
+	// Cache is CacheHit or CacheMiss for lookups in the fragment cache.
+	Cache string
+
+	// Duration, Bytes and Err are the time the render took, the size of its
+	// output and the error it failed with. They are only set after the
+	// render.
+	Duration time.Duration
+	Bytes    int
+	Err      error
+}
+
+// Hook is a set of callbacks that are called around each render.
+type Hook struct {
+	Before func(RenderEvent)
+	After  func(RenderEvent)
+}
+
+// instrument calls the hooks around fn, which returns the number of bytes it
+// rendered, and traces it if there is a tracer. The render is skipped if its
+// context is already done.
+func (r *Render) instrument(rc *renderContext, kind, name, layout string, fn func() (int, error)) error {
+	return r.instrumentEvent(rc, RenderEvent{Kind: kind, Template: name, Layout: layout}, fn)
+}
+
+// instrumentEvent is instrument for an event that has already been
+// described.
+func (r *Render) instrumentEvent(rc *renderContext, ev RenderEvent, fn func() (int, error)) error {
+	if err := rc.ctx.Err(); err != nil {
+		return err
+	}
+	if len(r.opt.Hooks) == 0 && r.opt.Tracer == nil {
+		_, err := fn()
+		return err
+	}
+
+	for _, h := range r.opt.Hooks {
+		if h.Before != nil {
+			h.Before(ev)
+		}
+	}
+
+	// Renders that happen during fn are children of this one.
+	parent := rc.ctx
+	var span Span
+	if r.opt.Tracer != nil {
+		rc.ctx, span = r.opt.Tracer.Start(parent, ev)
+	}
+
+	start := time.Now()
+	n, err := fn()
+	ev.Duration, ev.Bytes, ev.Err = time.Since(start), n, err
+
+	if span != nil {
+		span.End(ev)
+	}
+	rc.ctx = parent
+
+	for _, h := range r.opt.Hooks {
+		if h.After != nil {
+			h.After(ev)
+		}
+	}
+	return err
+}
+
+// SlogHook returns a hook that logs every render to the given logger. Renders
+// are logged at debug level, and failed renders at error level.
+func SlogHook(logger *slog.Logger) Hook {
+	return Hook{
+		After: func(ev RenderEvent) {
+			level := slog.LevelDebug
+			attrs := []slog.Attr{
+				slog.String("kind", ev.Kind),
+				slog.String("template", ev.Template),
+				slog.Duration("duration", ev.Duration),
+				slog.Int("bytes", ev.Bytes),
+			}
+			if ev.Layout != "" {
+				attrs = append(attrs, slog.String("layout", ev.Layout))
+			}
+			if ev.Cache != "" {
+				attrs = append(attrs, slog.String("cache", ev.Cache))
+			}
+			if ev.Err != nil {
+				level = slog.LevelError
+				attrs = append(attrs, slog.String("error", ev.Err.Error()))
+			}
+			logger.LogAttrs(context.Background(), level, "render", attrs...)
+		},
+	}
+}
+
+// DefaultBuckets are the upper bounds, in seconds, of the render duration
+// histogram buckets used by NewMetrics.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// Metrics collects a histogram of render durations for each template. It can
+// be served in the Prometheus text format, or published with expvar.
+//
+//	metrics := turbo.NewMetrics()
+//	render := turbo.New(turbo.Options{Hooks: []turbo.Hook{metrics.Hook()}})
+//	mux.Handle("/metrics", metrics)
+type Metrics struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[metricKey]*histogram
+	cache  map[string]*cacheCounts
+}
+
+type cacheCounts struct {
+	hits   uint64
+	misses uint64
+}
+
+type metricKey struct {
+	kind     string
+	template string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+	bytes  uint64
+	errors uint64
+}
+
+// NewMetrics returns metrics with the given histogram buckets, or with
+// DefaultBuckets if none are given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets: buckets,
+		series:  make(map[metricKey]*histogram),
+		cache:   make(map[string]*cacheCounts),
+	}
+}
+
+// Hook returns the hook that records renders.
+func (m *Metrics) Hook() Hook {
+	return Hook{After: m.observe}
+}
+
+func (m *Metrics) observe(ev RenderEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Cache lookups are counted on their own, since a miss is followed by
+	// the render of the partial anyway.
+	if ev.Kind == RenderCache {
+		c, ok := m.cache[ev.Template]
+		if !ok {
+			c = &cacheCounts{}
+			m.cache[ev.Template] = c
+		}
+		if ev.Cache == CacheHit {
+			c.hits++
+		} else {
+			c.misses++
+		}
+		return
+	}
+
+	key := metricKey{kind: ev.Kind, template: ev.Template}
+	h, ok := m.series[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.series[key] = h
+	}
+
+	seconds := ev.Duration.Seconds()
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+	h.bytes += uint64(ev.Bytes)
+	if ev.Err != nil {
+		h.errors++
+	}
+}
+
+// CacheStats returns the number of hits and misses in the fragment cache for
+// the template with the given name.
+func (m *Metrics) CacheStats(name string) (hits, misses uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.cache[name]; ok {
+		return c.hits, c.misses
+	}
+	return 0, 0
+}
+
+// keys returns the series in a stable order.
+func (m *Metrics) keys() []metricKey {
+	keys := make([]metricKey, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].template != keys[j].template {
+			return keys[i].template < keys[j].template
+		}
+		return keys[i].kind < keys[j].kind
+	})
+	return keys
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := &strings.Builder{}
+	b.WriteString("# HELP turbo_render_duration_seconds Time spent rendering templates.\n")
+	b.WriteString("# TYPE turbo_render_duration_seconds histogram\n")
+	for _, k := range m.keys() {
+		h := m.series[k]
+		labels := fmt.Sprintf(`kind=%q,template=%q`, k.kind, k.template)
+		for i, upper := range m.buckets {
+			fmt.Fprintf(b, "turbo_render_duration_seconds_bucket{%s,le=%q} %d\n", labels, strconv.FormatFloat(upper, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(b, "turbo_render_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(b, "turbo_render_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(b, "turbo_render_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	b.WriteString("# HELP turbo_render_bytes_total Bytes of output rendered by templates.\n")
+	b.WriteString("# TYPE turbo_render_bytes_total counter\n")
+	for _, k := range m.keys() {
+		fmt.Fprintf(b, "turbo_render_bytes_total{kind=%q,template=%q} %d\n", k.kind, k.template, m.series[k].bytes)
+	}
+
+	b.WriteString("# HELP turbo_render_errors_total Renders that failed.\n")
+	b.WriteString("# TYPE turbo_render_errors_total counter\n")
+	for _, k := range m.keys() {
+		fmt.Fprintf(b, "turbo_render_errors_total{kind=%q,template=%q} %d\n", k.kind, k.template, m.series[k].errors)
+	}
+
+	b.WriteString("# HELP turbo_fragment_cache_total Lookups in the fragment cache.\n")
+	b.WriteString("# TYPE turbo_fragment_cache_total counter\n")
+	names := make([]string, 0, len(m.cache))
+	for name := range m.cache {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := m.cache[name]
+		fmt.Fprintf(b, "turbo_fragment_cache_total{template=%q,result=%q} %d\n", name, CacheHit, c.hits)
+		fmt.Fprintf(b, "turbo_fragment_cache_total{template=%q,result=%q} %d\n", name, CacheMiss, c.misses)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// Publish publishes the metrics with expvar under the given name, so they
+// are served by expvar.Handler.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(m.snapshot))
+}
+
+func (m *Metrics) snapshot() interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type bucket struct {
+		Le    float64 `json:"le"`
+		Count uint64  `json:"count"`
+	}
+	type series struct {
+		Kind     string   `json:"kind"`
+		Template string   `json:"template"`
+		Count    uint64   `json:"count"`
+		Sum      float64  `json:"sum"`
+		Bytes    uint64   `json:"bytes"`
+		Errors   uint64   `json:"errors"`
+		Buckets  []bucket `json:"buckets"`
+	}
+
+	var out []series
+	for _, k := range m.keys() {
+		h := m.series[k]
+		s := series{Kind: k.kind, Template: k.template, Count: h.count, Sum: h.sum, Bytes: h.bytes, Errors: h.errors}
+		for i, upper := range m.buckets {
+			s.Buckets = append(s.Buckets, bucket{Le: upper, Count: h.counts[i]})
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package turbo_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Hooks(t *testing.T) {
+	var events []string
+	hook := turbo.Hook{
+		Before: func(ev turbo.RenderEvent) {
+			events = append(events, "before "+ev.Kind+" "+ev.Template)
+		},
+		After: func(ev turbo.RenderEvent) {
+			if ev.Duration <= 0 {
+				t.Errorf("expected render of %s to have a duration", ev.Template)
+			}
+			events = append(events, "after "+ev.Kind+" "+ev.Template)
+		},
+	}
+
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/hooks",
+		Layout:    "layout",
+		Hooks:     []turbo.Hook{hook},
+	})
+
+	t.Run("hooks are called for pages, yield and partials", func(t *testing.T) {
+		events = nil
+		const expected = `head<p>test</p>foot`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+
+		expectedEvents := []string{
+			"before page content",
+			"before yield content",
+			"after yield content",
+			"before partial _foot",
+			"after partial _foot",
+			"after page content",
+		}
+		if strings.Join(events, "\n") != strings.Join(expectedEvents, "\n") {
+			t.Fatalf("expected events %v but got %v", expectedEvents, events)
+		}
+	})
+
+	t.Run("hooks are called for partial renders", func(t *testing.T) {
+		events = nil
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if _, err := render.String(res, req, "content", "test", true); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+
+		expectedEvents := []string{"before partial content", "after partial content"}
+		if strings.Join(events, "\n") != strings.Join(expectedEvents, "\n") {
+			t.Fatalf("expected events %v but got %v", expectedEvents, events)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := turbo.NewMetrics()
+	logs := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/hooks",
+		Layout:    "layout",
+		Hooks:     []turbo.Hook{metrics.Hook(), turbo.SlogHook(logger)},
+	})
+
+	for i := 0; i < 2; i++ {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+	}
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	render.HTML(res, req, http.StatusOK, "missing", "test", true)
+
+	res = httptest.NewRecorder()
+	metrics.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := res.Body.String()
+
+	for _, expected := range []string{
+		`turbo_render_duration_seconds_bucket{kind="page",template="content",le="+Inf"} 2`,
+		`turbo_render_duration_seconds_count{kind="yield",template="content"} 2`,
+		`turbo_render_duration_seconds_count{kind="partial",template="_foot"} 2`,
+		`turbo_render_bytes_total{kind="page",template="content"} 38`,
+		`turbo_render_errors_total{kind="partial",template="missing"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected metrics to contain %s but got:\n%s", expected, body)
+		}
+	}
+
+	if !strings.Contains(logs.String(), `level=ERROR msg=render kind=partial template=missing`) {
+		t.Fatalf("expected failed render to be logged but got:\n%s", logs.String())
+	}
+}
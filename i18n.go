@@ -0,0 +1,529 @@
+package turbo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLocaleCookieName is the default name of the cookie that holds the
+// locale chosen by the user.
+const DefaultLocaleCookieName = "locale"
+
+// PluralRule picks the plural form for a count, ie, "zero", "one" or
+// "other".
+type PluralRule func(n float64) string
+
+// pluralRules are the plural rules for the locales that don't follow the
+// English rule.
+var pluralRules = map[string]PluralRule{
+	// In French, zero is singular too.
+	"fr": func(n float64) string {
+		if n < 2 {
+			return "one"
+		}
+		return "other"
+	},
+}
+
+func englishPlural(n float64) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// I18n holds the message catalogs for each locale, and works out which
+// locale a request wants.
+//
+// Catalogs are JSON or TOML files named after their locale, ie, en.json and
+// fr.toml. Nested keys are joined with dots, and a message with plural forms
+// is an object with "zero", "one" and "other" keys:
+//
+//	{
+//		"users": {
+//			"greeting": "Hello, %{name}!",
+//			"count": {"one": "%{count} user", "other": "%{count} users"}
+//		}
+//	}
+//
+// When it is set on Options, the t and l template helpers translate and
+// localize in the locale of the request, and Render.HTML prefers
+// locale-specific templates, like users/show.fr.tmpl, when they exist.
+type I18n struct {
+	// Default is the locale used when the request doesn't ask for one we
+	// have, and for messages that are missing from a catalog.
+	Default string
+
+	// CookieName is the name of the cookie that holds the locale chosen by
+	// the user. It defaults to DefaultLocaleCookieName.
+	CookieName string
+
+	// PluralRules overrides the plural rule for each locale. Locales that
+	// don't have one use the English rule.
+	PluralRules map[string]PluralRule
+
+	catalogs map[string]map[string]interface{}
+}
+
+// NewI18n loads every catalog in the given filesystem. Use os.DirFS to load
+// them from a directory.
+func NewI18n(fsys fs.FS, defaultLocale string) (*I18n, error) {
+	i := &I18n{
+		Default:  defaultLocale,
+		catalogs: make(map[string]map[string]interface{}),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		ext := path.Ext(name)
+		if ext != ".json" && ext != ".toml" {
+			return nil
+		}
+		buf, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		var raw map[string]interface{}
+		if ext == ".json" {
+			err = json.Unmarshal(buf, &raw)
+		} else {
+			raw, err = parseTOML(string(buf))
+		}
+		if err != nil {
+			return fmt.Errorf("turbo: loading catalog %s: %v", name, err)
+		}
+
+		locale := strings.TrimSuffix(path.Base(name), ext)
+		if i.catalogs[locale] == nil {
+			i.catalogs[locale] = make(map[string]interface{})
+		}
+		flatten(i.catalogs[locale], "", raw)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// flatten adds the messages in raw to the catalog, with nested keys joined
+// by dots. Objects of plural forms are kept as they are.
+func flatten(catalog map[string]interface{}, prefix string, raw map[string]interface{}) {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		m, ok := v.(map[string]interface{})
+		if ok && !isPlural(m) {
+			flatten(catalog, key, m)
+			continue
+		}
+		catalog[key] = v
+	}
+}
+
+// isPlural reports whether the object holds plural forms.
+func isPlural(m map[string]interface{}) bool {
+	_, ok := m["other"]
+	return ok
+}
+
+// Locales returns every locale that has a catalog.
+func (i *I18n) Locales() []string {
+	locales := make([]string, 0, len(i.catalogs))
+	for locale := range i.catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Translate returns the message for the given key in the given locale,
+// falling back to the default locale, and then to the key itself.
+//
+// The args are the values interpolated into the message, either as a single
+// map or as pairs of names and values. The "count" value picks the plural
+// form of the message.
+func (i *I18n) Translate(locale, key string, args ...interface{}) string {
+	params := i18nParams(args)
+
+	msg, ok := i.catalogs[locale][key]
+	if !ok {
+		if msg, ok = i.catalogs[i.Default][key]; !ok {
+			return key
+		}
+		locale = i.Default
+	}
+
+	if forms, ok := msg.(map[string]interface{}); ok {
+		msg = forms[i.pluralForm(locale, forms, params["count"])]
+	}
+
+	s, ok := msg.(string)
+	if !ok {
+		return key
+	}
+	return interpolate(s, params)
+}
+
+// pluralForm picks the plural form for the count from the available forms.
+func (i *I18n) pluralForm(locale string, forms map[string]interface{}, count interface{}) string {
+	n, ok := toFloat(count)
+	if !ok {
+		return "other"
+	}
+	if _, ok := forms["zero"]; ok && n == 0 {
+		return "zero"
+	}
+
+	rule, ok := i.PluralRules[locale]
+	if !ok {
+		if rule, ok = pluralRules[baseLocale(locale)]; !ok {
+			rule = englishPlural
+		}
+	}
+
+	if form := rule(n); forms[form] != nil {
+		return form
+	}
+	return "other"
+}
+
+// Localize formats times and numbers for the given locale. Times are
+// formatted with the Go layout in the "formats.<format>" message, and
+// numbers use the "number.delimiter" and "number.separator" messages.
+func (i *I18n) Localize(locale string, v interface{}, format ...string) string {
+	switch v := v.(type) {
+	case time.Time:
+		name := "default"
+		for _, f := range format {
+			name = f
+		}
+		layout := i.Translate(locale, "formats."+name)
+		if layout == "formats."+name {
+			layout = "2006-01-02"
+		}
+		return v.Format(layout)
+	case time.Duration:
+		return v.String()
+	}
+
+	n, ok := toFloat(v)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	delimiter := i.message(locale, "number.delimiter", ",")
+	separator := i.message(locale, "number.separator", ".")
+
+	s := strconv.FormatFloat(n, 'f', -1, 64)
+	integer, fraction := s, ""
+	if dot := strings.IndexByte(s, '.'); dot != -1 {
+		integer, fraction = s[:dot], s[dot+1:]
+	}
+
+	sign := ""
+	if strings.HasPrefix(integer, "-") {
+		sign, integer = "-", integer[1:]
+	}
+	for j := len(integer) - 3; j > 0; j -= 3 {
+		integer = integer[:j] + delimiter + integer[j:]
+	}
+	if fraction != "" {
+		return sign + integer + separator + fraction
+	}
+	return sign + integer
+}
+
+// message returns the message for the key, or the fallback if it's missing.
+func (i *I18n) message(locale, key, fallback string) string {
+	if msg := i.Translate(locale, key); msg != key {
+		return msg
+	}
+	return fallback
+}
+
+// Funcs returns the t and l template helpers for the given locale.
+func (i *I18n) Funcs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(key string, args ...interface{}) string {
+			return i.Translate(locale, key, args...)
+		},
+		"l": func(v interface{}, format ...string) string {
+			return i.Localize(locale, v, format...)
+		},
+		"locale": func() string {
+			return locale
+		},
+	}
+}
+
+// Match returns the locale we have that best matches the given one, or an
+// empty string if there isn't one. A regional locale like fr-CA matches fr.
+func (i *I18n) Match(locale string) string {
+	locale = strings.Replace(strings.TrimSpace(locale), "_", "-", -1)
+	if l := i.lookup(locale); l != "" {
+		return l
+	}
+	return i.lookup(baseLocale(locale))
+}
+
+// lookup returns the locale whose catalog has the given name, ignoring case,
+// or an empty string if there isn't one.
+func (i *I18n) lookup(locale string) string {
+	for l := range i.catalogs {
+		if strings.EqualFold(l, locale) {
+			return l
+		}
+	}
+	return ""
+}
+
+// Detect returns the locale of the request. In order, it uses the locale set
+// by Handler, the locale cookie, the Accept-Language header, and finally the
+// default locale.
+func (i *I18n) Detect(r *http.Request) string {
+	locale, _ := i.detect(r)
+	return locale
+}
+
+// detect is Detect, but also returns the request headers that the locale
+// depends on, for the Vary header of the response.
+func (i *I18n) detect(r *http.Request) (string, []string) {
+	if locale, ok := r.Context().Value(localeContextKey{}).(string); ok {
+		return locale, nil
+	}
+
+	if cookie, err := r.Cookie(i.cookieName()); err == nil {
+		if locale := i.Match(cookie.Value); locale != "" {
+			return locale, []string{"Cookie"}
+		}
+	}
+
+	// Sending the cookie would change the locale too.
+	vary := []string{"Cookie", "Accept-Language"}
+	for _, tag := range acceptLanguages(r.Header.Get("Accept-Language")) {
+		if locale := i.Match(tag); locale != "" {
+			return locale, vary
+		}
+	}
+	return i.Default, vary
+}
+
+// addVary adds the given names to the Vary header, unless they're already
+// there.
+func addVary(h http.Header, names ...string) {
+	for _, name := range names {
+		found := false
+		for _, v := range h.Values("Vary") {
+			for _, field := range strings.Split(v, ",") {
+				if strings.EqualFold(strings.TrimSpace(field), name) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			h.Add("Vary", name)
+		}
+	}
+}
+
+func (i *I18n) cookieName() string {
+	if i.CookieName != "" {
+		return i.CookieName
+	}
+	return DefaultLocaleCookieName
+}
+
+type localeContextKey struct{}
+
+// Handler is a middleware that detects the locale of each request. The name
+// of a catalog at the start of the URL path, like /fr/users, wins over
+// everything else, and is removed from the path before the request is
+// handled. Unlike cookies and Accept-Language, regional locales in the path
+// don't fall back to their language. Otherwise, the
+// Cookie and Accept-Language headers that the locale was detected from are
+// added to the Vary header of the response.
+func (i *I18n) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := ""
+
+		// Paths like /en-route aren't a regional locale.
+		segment := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		if l := i.lookup(segment[0]); l != "" {
+			locale = l
+
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = "/"
+			if len(segment) > 1 {
+				r2.URL.Path += segment[1]
+			}
+			r2.URL.RawPath = ""
+			r = r2
+		} else {
+			var vary []string
+			locale, vary = i.detect(r)
+			addVary(w.Header(), vary...)
+		}
+
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localeContextKey{}, locale)))
+	})
+}
+
+// Locale returns the locale of the request that was detected by
+// I18n.Handler, or an empty string if there isn't one.
+func Locale(r *http.Request) string {
+	locale, _ := r.Context().Value(localeContextKey{}).(string)
+	return locale
+}
+
+// localize returns the name of the locale-specific variant of the template
+// if there is one for the locale of the request.
+func (r *Render) localize(rc *renderContext, req *http.Request, name string) string {
+	if r.opt.I18n == nil {
+		return name
+	}
+
+	locale := r.opt.I18n.Detect(req)
+	if rc.templates.Lookup(name+"."+locale) != nil {
+		return name + "." + locale
+	}
+	return name
+}
+
+// acceptLanguages returns the language tags in the Accept-Language header,
+// ordered by preference.
+func acceptLanguages(header string) []string {
+	type tag struct {
+		name string
+		q    float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{name: strings.TrimSpace(fields[0]), q: 1}
+		if t.name == "" || t.name == "*" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				t.q, _ = strconv.ParseFloat(v[2:], 64)
+			}
+		}
+		if t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.name
+	}
+	return names
+}
+
+// baseLocale returns the language of the locale, ie, "fr" for "fr-CA".
+func baseLocale(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i != -1 {
+		return locale[:i]
+	}
+	return locale
+}
+
+// i18nParams turns the args of Translate into a map.
+func i18nParams(args []interface{}) map[string]interface{} {
+	if len(args) == 1 {
+		if m, ok := args[0].(map[string]interface{}); ok {
+			return m
+		}
+	}
+
+	params := make(map[string]interface{}, len(args)/2)
+	for j := 0; j+1 < len(args); j += 2 {
+		params[fmt.Sprint(args[j])] = args[j+1]
+	}
+	return params
+}
+
+// interpolate replaces each %{name} in the message with its value.
+func interpolate(msg string, params map[string]interface{}) string {
+	if len(params) == 0 || !strings.Contains(msg, "%{") {
+		return msg
+	}
+
+	b := &strings.Builder{}
+	for {
+		start := strings.Index(msg, "%{")
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(msg[start:], '}')
+		if end == -1 {
+			break
+		}
+
+		name := msg[start+2 : start+end]
+		b.WriteString(msg[:start])
+		if v, ok := params[name]; ok {
+			fmt.Fprint(b, v)
+		} else {
+			b.WriteString(msg[start : start+end+1])
+		}
+		msg = msg[start+end+1:]
+	}
+	b.WriteString(msg)
+	return b.String()
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
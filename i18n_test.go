@@ -0,0 +1,148 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestI18n(t *testing.T) {
+	i18n, err := turbo.NewI18n(os.DirFS("fixtures/i18n/locales"), "en")
+	if err != nil {
+		t.Fatalf("unexpected error loading catalogs: %v", err)
+	}
+
+	t.Run("translate", func(t *testing.T) {
+		tests := []struct {
+			locale   string
+			key      string
+			args     []interface{}
+			expected string
+		}{
+			{"en", "title", nil, "Users"},
+			{"fr", "title", nil, "Utilisateurs"},
+			{"en", "greeting", []interface{}{"name", "Ben"}, "Hello, Ben!"},
+			{"fr", "greeting", []interface{}{map[string]interface{}{"name": "Ben"}}, "Bonjour, Ben !"},
+			{"en", "users.count", []interface{}{"count", 0}, "No users"},
+			{"en", "users.count", []interface{}{"count", 1}, "1 user"},
+			{"en", "users.count", []interface{}{"count", 2}, "2 users"},
+			{"fr", "users.count", []interface{}{"count", 0}, "0 utilisateur"},
+			{"fr", "users.count", []interface{}{"count", 2}, "2 utilisateurs"},
+			{"fr", "missing", nil, "missing"},
+			{"de", "title", nil, "Users"},
+		}
+
+		for _, tt := range tests {
+			if actual := i18n.Translate(tt.locale, tt.key, tt.args...); actual != tt.expected {
+				t.Errorf("expected %s.%s to translate to %q but got %q", tt.locale, tt.key, tt.expected, actual)
+			}
+		}
+	})
+
+	t.Run("localize", func(t *testing.T) {
+		date := time.Date(2018, time.March, 4, 0, 0, 0, 0, time.UTC)
+
+		tests := []struct {
+			locale   string
+			v        interface{}
+			expected string
+		}{
+			{"en", date, "March 4, 2018"},
+			{"fr", date, "04/03/2018"},
+			{"en", 1234567.5, "1,234,567.5"},
+			{"fr", 1234567.5, "1 234 567,5"},
+			{"en", -1000, "-1,000"},
+		}
+
+		for _, tt := range tests {
+			if actual := i18n.Localize(tt.locale, tt.v); actual != tt.expected {
+				t.Errorf("expected %v to localize to %q in %s but got %q", tt.v, tt.expected, tt.locale, actual)
+			}
+		}
+	})
+
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/i18n/views",
+		Layout:    "layout",
+		I18n:      i18n,
+	})
+	h := i18n.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users" {
+			t.Errorf("expected locale to be removed from path but got %s", r.URL.Path)
+		}
+		if err := render.HTML(w, r, http.StatusOK, "content", 2); err != nil {
+			t.Errorf("unexpected error rendering template: %v", err)
+		}
+	}))
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		cookie   string
+		expected string
+		vary     []string
+	}{
+		{"default locale", "/users", "", "", `Users|<p>2 users</p>`, []string{"Cookie", "Accept-Language"}},
+		{"Accept-Language header", "/users", "de-DE, fr-CA;q=0.8, en;q=0.5", "", `Utilisateurs|<p lang="fr">2 utilisateurs</p>`, []string{"Cookie", "Accept-Language"}},
+		{"locale cookie", "/users", "fr", "en", `Users|<p>2 users</p>`, []string{"Cookie"}},
+		{"URL prefix", "/fr/users", "en", "en", `Utilisateurs|<p lang="fr">2 utilisateurs</p>`, nil},
+		{"URL prefix ignores case", "/FR/users", "en", "en", `Utilisateurs|<p lang="fr">2 utilisateurs</p>`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Accept-Language", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: turbo.DefaultLocaleCookieName, Value: tt.cookie})
+			}
+			h.ServeHTTP(res, req)
+
+			if body := res.Body.String(); body != tt.expected {
+				t.Fatalf("expected %s but got %s", tt.expected, body)
+			}
+			if vary := res.Header().Values("Vary"); !reflect.DeepEqual(vary, tt.vary) {
+				t.Fatalf("expected Vary %v but got %v", tt.vary, vary)
+			}
+		})
+	}
+
+	t.Run("paths that start like a locale", func(t *testing.T) {
+		for _, path := range []string{"/en-route/x", "/fr-ca/x"} {
+			var actualPath, actualLocale string
+			h := i18n.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actualPath, actualLocale = r.URL.Path, turbo.Locale(r)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+
+			if actualPath != path {
+				t.Fatalf("expected %s to pass through unchanged but got %s", path, actualPath)
+			}
+			if actualLocale != "en" {
+				t.Fatalf("expected default locale for %s but got %s", path, actualLocale)
+			}
+		}
+	})
+
+	t.Run("without the handler", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.AddCookie(&http.Cookie{Name: turbo.DefaultLocaleCookieName, Value: "fr"})
+		if err := render.HTML(res, req, http.StatusOK, "content", 2); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+
+		if vary := res.Header().Values("Vary"); !reflect.DeepEqual(vary, []string{"Cookie"}) {
+			t.Fatalf("expected Vary: Cookie but got %v", vary)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package turbo
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	styleElementRe = regexp.MustCompile(`(?is)<style[^>]*>(.*?)</style>`)
+	cssCommentRe   = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	simpleSelector = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*)?(#[A-Za-z0-9_-]+)?((?:\.[A-Za-z0-9_-]+)*)$`)
+	startTagRe     = regexp.MustCompile(`<([A-Za-z][A-Za-z0-9-]*)(\s[^<>]*?)?(\s*/?)>`)
+	attrRe         = regexp.MustCompile(`(?i)\s(class|id|style)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// cssRule is a rule with a simple selector, which can be inlined.
+type cssRule struct {
+	tag     string
+	id      string
+	classes []string
+	decls   string
+
+	specificity int
+	order       int
+}
+
+// matches reports whether the rule applies to an element.
+func (rule *cssRule) matches(tag, id string, classes map[string]bool) bool {
+	if rule.tag != "" && !strings.EqualFold(rule.tag, tag) {
+		return false
+	}
+	if rule.id != "" && rule.id != id {
+		return false
+	}
+	for _, c := range rule.classes {
+		if !classes[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineCSS moves the rules from the style elements of the document into the
+// style attributes of the elements that they match. Only rules with simple
+// selectors, made of a tag, an id and classes, can be inlined. Everything
+// else, like at-rules and pseudo-classes, is left in the style element.
+// Declarations that are already in a style attribute win.
+func inlineCSS(doc string) string {
+	var rules []*cssRule
+
+	doc = styleElementRe.ReplaceAllStringFunc(doc, func(style string) string {
+		css := styleElementRe.FindStringSubmatch(style)[1]
+		kept := parseCSS(cssCommentRe.ReplaceAllString(css, ""), &rules)
+		if strings.TrimSpace(kept) == "" {
+			return ""
+		}
+		return "<style>" + kept + "</style>"
+	})
+	if len(rules) == 0 {
+		return doc
+	}
+
+	// More specific rules win, and then later ones.
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].specificity != rules[j].specificity {
+			return rules[i].specificity < rules[j].specificity
+		}
+		return rules[i].order < rules[j].order
+	})
+
+	return startTagRe.ReplaceAllStringFunc(doc, func(tag string) string {
+		m := startTagRe.FindStringSubmatch(tag)
+		name, attrs, end := m[1], m[2], m[3]
+
+		var id, style string
+		classes := make(map[string]bool)
+		for _, a := range attrRe.FindAllStringSubmatch(attrs, -1) {
+			value := a[2] + a[3]
+			switch strings.ToLower(a[1]) {
+			case "id":
+				id = value
+			case "class":
+				for _, c := range strings.Fields(value) {
+					classes[c] = true
+				}
+			case "style":
+				style = html.UnescapeString(value)
+			}
+		}
+
+		var decls []string
+		for _, rule := range rules {
+			if rule.matches(name, id, classes) {
+				decls = append(decls, rule.decls)
+			}
+		}
+		if len(decls) == 0 {
+			return tag
+		}
+		if style = strings.TrimSpace(style); style != "" {
+			decls = append(decls, strings.TrimSuffix(style, ";"))
+		}
+
+		attrs = attrRe.ReplaceAllStringFunc(attrs, func(a string) string {
+			if strings.EqualFold(attrRe.FindStringSubmatch(a)[1], "style") {
+				return ""
+			}
+			return a
+		})
+		return "<" + name + attrs + ` style="` + html.EscapeString(strings.Join(decls, "; ")) + `"` + end + ">"
+	})
+}
+
+// parseCSS adds the rules of the stylesheet that can be inlined to rules,
+// and returns the CSS that can't be.
+func parseCSS(css string, rules *[]*cssRule) string {
+	var kept strings.Builder
+
+	for {
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(css[:open])
+
+		// Find the matching brace, since at-rules nest.
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		block := css[open+1 : end]
+		css = css[end+1:]
+
+		if strings.HasPrefix(prelude, "@") {
+			kept.WriteString(prelude + "{" + block + "}")
+			continue
+		}
+
+		decls := strings.TrimSuffix(strings.TrimSpace(block), ";")
+		var complex []string
+		for _, selector := range strings.Split(prelude, ",") {
+			selector = strings.TrimSpace(selector)
+			m := simpleSelector.FindStringSubmatch(selector)
+			if m == nil || selector == "" {
+				complex = append(complex, selector)
+				continue
+			}
+
+			rule := &cssRule{
+				tag:   m[1],
+				id:    strings.TrimPrefix(m[2], "#"),
+				decls: decls,
+				order: len(*rules),
+			}
+			if m[3] != "" {
+				rule.classes = strings.Split(m[3][1:], ".")
+			}
+			rule.specificity = len(rule.classes) * 10
+			if rule.id != "" {
+				rule.specificity += 100
+			}
+			if rule.tag != "" {
+				rule.specificity++
+			}
+			*rules = append(*rules, rule)
+		}
+		if len(complex) > 0 {
+			kept.WriteString(strings.Join(complex, ", ") + "{" + block + "}")
+		}
+	}
+
+	return kept.String()
+}
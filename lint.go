@@ -0,0 +1,249 @@
+package turbo
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// parseErrorRe matches the errors returned by text/template when parsing
+// fails, ie, `template: users/show:3: function "foo" not defined`.
+var parseErrorRe = regexp.MustCompile(`^template: [^:]*:(\d+):(?:\d+:)? (.*)$`)
+
+// LintError is a problem with a template that was found by Lint.
+type LintError struct {
+	// File is the path to the template.
+	File string
+
+	// Line is the line the problem is on, or zero if it isn't on any line
+	// in particular.
+	Line int
+
+	// Message describes the problem.
+	Message string
+}
+
+func (e *LintError) Error() string {
+	if e.Line == 0 {
+		return e.File + ": " + e.Message
+	}
+	return e.File + ":" + strconv.Itoa(e.Line) + ": " + e.Message
+}
+
+// Lint parses every template in the template directory with the same rules
+// that New does, and reports all of the errors instead of panicking on the
+// first one. It also reports references to templates that don't exist, and
+// partials that are never used. A template is a partial if its name starts
+// with an underscore, or if it's in a directory called partials.
+//
+// The templates of each theme are linted as they are when the theme is in
+// use, and a partial only needs to be used by one of them.
+func Lint(opts Options) []*LintError {
+	r := &Render{opt: &opts}
+	r.prepareRender()
+
+	l := &linter{
+		r:        r,
+		partials: make(map[string]string),
+		used:     make(map[string]bool),
+		seen:     make(map[LintError]bool),
+	}
+	for _, theme := range r.themes() {
+		l.lint(theme)
+	}
+
+	for file, name := range l.partials {
+		if !l.used[file] {
+			l.report(&LintError{
+				File:    file,
+				Message: fmt.Sprintf("partial %q is never used", name),
+			})
+		}
+	}
+
+	sort.SliceStable(l.errs, func(i, j int) bool {
+		if l.errs[i].File != l.errs[j].File {
+			return l.errs[i].File < l.errs[j].File
+		}
+		if l.errs[i].Line != l.errs[j].Line {
+			return l.errs[i].Line < l.errs[j].Line
+		}
+		return l.errs[i].Message < l.errs[j].Message
+	})
+	return l.errs
+}
+
+// linter collects the problems found in the templates of every theme.
+type linter struct {
+	r *Render
+
+	// partials are the names of the partials, keyed by their file, and
+	// used are the files that are used by another template.
+	partials map[string]string
+	used     map[string]bool
+
+	seen map[LintError]bool
+	errs []*LintError
+}
+
+// report adds the error, unless it was already found in another theme.
+func (l *linter) report(err *LintError) {
+	if l.seen[*err] {
+		return
+	}
+	l.seen[*err] = true
+	l.errs = append(l.errs, err)
+}
+
+// lint lints the templates of the given theme.
+func (l *linter) lint(theme string) {
+	// Templates are parsed into the same set, so that references between
+	// them can be resolved, but each failure is kept to itself.
+	set := template.New(l.r.opt.Directory)
+	files := make(map[string]string)
+
+	l.r.walkTemplates(theme, func(name, file string, buf []byte) {
+		files[name] = file
+		if isPartial(name) {
+			l.partials[file] = name
+		}
+
+		src, err := l.r.process(name, filepath.Ext(file), buf)
+		if err != nil {
+			l.report(lintParseError(file, err))
+			return
+		}
+
+		tmpl := template.New(name)
+		tmpl.Delims(src.LeftDelim, src.RightDelim)
+		l.r.addParseFuncs(tmpl)
+
+		if _, err := tmpl.Parse(string(src.Body)); err != nil {
+			l.report(lintParseError(file, err))
+			return
+		}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				set.AddParseTree(t.Name(), t.Tree)
+			}
+		}
+	})
+
+	for _, tmpl := range set.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+
+		walkTemplateRefs(tmpl.Tree.Root, func(name string, n parse.Node) {
+			if file, ok := files[name]; ok {
+				l.used[file] = true
+			}
+			if t := set.Lookup(name); t != nil && t.Tree != nil {
+				return
+			}
+
+			// Templates from define blocks are attributed to the file that
+			// defines them by the parser.
+			file, ok := files[tmpl.Tree.ParseName]
+			if !ok {
+				file = tmpl.Tree.ParseName
+			}
+			l.report(&LintError{
+				File:    file,
+				Line:    lintLine(tmpl.Tree, n),
+				Message: fmt.Sprintf("template %q is not defined", name),
+			})
+		})
+	}
+}
+
+// lintParseError converts an error from the template parser into a lint
+// error.
+func lintParseError(file string, err error) *LintError {
+	m := parseErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return &LintError{File: file, Message: err.Error()}
+	}
+
+	line, _ := strconv.Atoi(m[1])
+	return &LintError{File: file, Line: line, Message: m[2]}
+}
+
+// lintLine returns the line that the node is on.
+func lintLine(tree *parse.Tree, n parse.Node) int {
+	location, _ := tree.ErrorContext(n)
+
+	// The location is formatted as "name:line:col".
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return 0
+	}
+	line, _ := strconv.Atoi(parts[len(parts)-2])
+	return line
+}
+
+// isPartial reports whether the template with the given name is a partial.
+func isPartial(name string) bool {
+	dir, base := path.Split(name)
+	return strings.HasPrefix(base, "_") || strings.HasPrefix(dir, "partials/") || strings.Contains(dir, "/partials/")
+}
+
+// walkTemplateRefs calls fn for every reference to another template under
+// the given node, from either the template action or the partial helper.
+func walkTemplateRefs(n parse.Node, fn func(name string, n parse.Node)) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, node := range n.Nodes {
+			walkTemplateRefs(node, fn)
+		}
+	case *parse.ActionNode:
+		walkPartialRefs(n.Pipe, fn)
+	case *parse.IfNode:
+		walkPartialRefs(n.Pipe, fn)
+		walkTemplateRefs(n.List, fn)
+		walkTemplateRefs(n.ElseList, fn)
+	case *parse.RangeNode:
+		walkPartialRefs(n.Pipe, fn)
+		walkTemplateRefs(n.List, fn)
+		walkTemplateRefs(n.ElseList, fn)
+	case *parse.WithNode:
+		walkPartialRefs(n.Pipe, fn)
+		walkTemplateRefs(n.List, fn)
+		walkTemplateRefs(n.ElseList, fn)
+	case *parse.TemplateNode:
+		fn(n.Name, n)
+	}
+}
+
+// walkPartialRefs calls fn for every call to the partial or cache helpers
+// with a constant template name in the given pipeline.
+func walkPartialRefs(pipe *parse.PipeNode, fn func(name string, n parse.Node)) {
+	if pipe == nil {
+		return
+	}
+
+	for _, cmd := range pipe.Cmds {
+		for i, arg := range cmd.Args {
+			switch arg := arg.(type) {
+			case *parse.IdentifierNode:
+				if (arg.Ident != "partial" && arg.Ident != "cache") || i+1 >= len(cmd.Args) {
+					continue
+				}
+				if name, ok := cmd.Args[i+1].(*parse.StringNode); ok {
+					fn(name.Text, cmd)
+				}
+			case *parse.PipeNode:
+				walkPartialRefs(arg, fn)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package turbo_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestLint(t *testing.T) {
+	t.Run("lint valid templates", func(t *testing.T) {
+		if errs := turbo.Lint(turbo.Options{Directory: "fixtures/basic"}); len(errs) != 0 {
+			t.Fatalf("expected no lint errors but got %v", errs)
+		}
+	})
+
+	t.Run("lint invalid templates", func(t *testing.T) {
+		errs := turbo.Lint(turbo.Options{Directory: "fixtures/lint"})
+
+		dir := filepath.FromSlash("fixtures/lint/")
+		expected := []string{
+			dir + `broken.tmpl:2: function "unknown" not defined`,
+			dir + `layout.tmpl:3: template "missing" is not defined`,
+			dir + filepath.FromSlash(`partials/sidebar.tmpl: partial "partials/sidebar" is never used`),
+			dir + `unclosed.tmpl:2: unexpected EOF`,
+		}
+		if len(errs) != len(expected) {
+			t.Fatalf("expected %d lint errors but got %d: %v", len(expected), len(errs), errs)
+		}
+		for i, err := range errs {
+			if err.Error() != expected[i] {
+				t.Fatalf("expected lint error %q but got %q", expected[i], err.Error())
+			}
+		}
+	})
+}
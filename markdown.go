@@ -0,0 +1,477 @@
+package turbo
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Markdown converts the Markdown in the source to HTML. It supports the
+// common subset of Markdown: headings, paragraphs, block quotes, ordered and
+// unordered lists, fenced and indented code blocks, thematic breaks, raw
+// HTML blocks, emphasis, code spans, links, images and autolinks.
+//
+// Template actions are copied as they are, wherever they appear. A
+// paragraph that only holds actions, like {{ range . }}, is written without
+// a paragraph around it.
+func Markdown(src *Source) error {
+	body, actions := protectActions(string(src.Body), src.LeftDelim, src.RightDelim)
+	out := markdownBlocks(strings.Split(body, "\n"))
+	src.Body = []byte(restoreActions(out, actions))
+	return nil
+}
+
+// actionMarker surrounds the index of an action while the Markdown is
+// converted. NUL never appears in a template.
+const actionMarker = "\x00"
+
+var actionRe = regexp.MustCompile("\x00[0-9]+\x00")
+
+// protectActions replaces the actions in s with markers, so that they aren't
+// treated as Markdown.
+func protectActions(s, left, right string) (string, []string) {
+	var actions []string
+	b := &strings.Builder{}
+	for {
+		i := strings.Index(s, left)
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		j := strings.Index(s[i+len(left):], right)
+		if j < 0 {
+			b.WriteString(s)
+			break
+		}
+		end := i + len(left) + j + len(right)
+
+		b.WriteString(s[:i])
+		b.WriteString(actionMarker + strconv.Itoa(len(actions)) + actionMarker)
+		actions = append(actions, s[i:end])
+		s = s[end:]
+	}
+	return b.String(), actions
+}
+
+// restoreActions puts the actions back in place of their markers.
+func restoreActions(s string, actions []string) string {
+	return actionRe.ReplaceAllStringFunc(s, func(m string) string {
+		i, _ := strconv.Atoi(strings.Trim(m, actionMarker))
+		return actions[i]
+	})
+}
+
+var (
+	headingRe     = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	breakRe       = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRe       = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^`\\s]*)")
+	bulletRe      = regexp.MustCompile(`^ {0,3}([-*+])[ \t]+`)
+	orderedRe     = regexp.MustCompile(`^ {0,3}([0-9]{1,9})[.)][ \t]+`)
+	htmlBlockRe   = regexp.MustCompile(`^ {0,3}</?[A-Za-z][A-Za-z0-9-]*(?:[\s/>]|$)`)
+	onlyActionsRe = regexp.MustCompile("^(?:\x00[0-9]+\x00\\s*)+$")
+)
+
+// markdownBlocks converts the given lines of Markdown to HTML.
+func markdownBlocks(lines []string) string {
+	b := &strings.Builder{}
+	var para []string
+
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		text := strings.TrimSpace(strings.Join(para, "\n"))
+		if onlyActionsRe.MatchString(text) {
+			b.WriteString(text + "\n")
+		} else {
+			b.WriteString("<p>" + markdownInline(text) + "</p>\n")
+		}
+		para = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		// Fenced code blocks run to the closing fence.
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			flush()
+			var code []string
+			for i++; i < len(lines); i++ {
+				l := strings.TrimRight(lines[i], "\r")
+				if strings.HasPrefix(strings.TrimSpace(l), m[1]) {
+					break
+				}
+				code = append(code, l)
+			}
+			writeCode(b, code, m[2])
+			continue
+		}
+
+		// Indented code blocks can't interrupt a paragraph.
+		if len(para) == 0 && isIndentedCode(line) {
+			var code []string
+			for ; i < len(lines); i++ {
+				l := strings.TrimRight(lines[i], "\r")
+				if strings.TrimSpace(l) != "" && !isIndentedCode(l) {
+					break
+				}
+				code = append(code, dedentCode(l))
+			}
+			i--
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			writeCode(b, code, "")
+			continue
+		}
+
+		if m := headingRe.FindStringSubmatch(strings.TrimLeft(line, " ")); m != nil && indent(line) < 4 {
+			flush()
+			level := len(m[1])
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, markdownInline(m[2]), level)
+			continue
+		}
+
+		if breakRe.MatchString(line) {
+			flush()
+			b.WriteString("<hr>\n")
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimLeft(line, " "), ">") {
+			flush()
+			var quote []string
+			for ; i < len(lines); i++ {
+				l := strings.TrimLeft(strings.TrimRight(lines[i], "\r"), " ")
+				if !strings.HasPrefix(l, ">") {
+					break
+				}
+				l = strings.TrimPrefix(l, ">")
+				quote = append(quote, strings.TrimPrefix(l, " "))
+			}
+			i--
+			b.WriteString("<blockquote>\n" + markdownBlocks(quote) + "</blockquote>\n")
+			continue
+		}
+
+		if bulletRe.MatchString(line) || orderedRe.MatchString(line) {
+			flush()
+			i = writeList(b, lines, i) - 1
+			continue
+		}
+
+		// Raw HTML runs to the next blank line.
+		if len(para) == 0 && htmlBlockRe.MatchString(line) {
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				b.WriteString(strings.TrimRight(lines[i], "\r") + "\n")
+			}
+			continue
+		}
+
+		para = append(para, line)
+	}
+	flush()
+
+	return b.String()
+}
+
+// indent returns the number of spaces at the start of the line.
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isIndentedCode(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+func dedentCode(line string) string {
+	if strings.HasPrefix(line, "\t") {
+		return line[1:]
+	}
+	if len(line) >= 4 {
+		return line[4:]
+	}
+	return strings.TrimLeft(line, " ")
+}
+
+// writeCode writes a code block. Its contents are escaped, but not treated
+// as Markdown.
+func writeCode(b *strings.Builder, code []string, lang string) {
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	b.WriteString(">")
+	for _, l := range code {
+		b.WriteString(escapeText(l) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// writeList writes the list that starts at the given line, and returns the
+// index of the first line after it.
+func writeList(b *strings.Builder, lines []string, i int) int {
+	ordered := orderedRe.MatchString(lines[i])
+	itemRe := bulletRe
+	if ordered {
+		itemRe = orderedRe
+		start := orderedRe.FindStringSubmatch(lines[i])[1]
+		if n, _ := strconv.Atoi(start); n != 1 {
+			fmt.Fprintf(b, "<ol start=\"%d\">\n", n)
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	var item []string
+	writeItem := func() {
+		if len(item) == 0 {
+			return
+		}
+		if len(item) == 1 {
+			b.WriteString("<li>" + markdownInline(strings.TrimSpace(item[0])) + "</li>\n")
+		} else {
+			// The first line of an item with a nested block is kept tight.
+			rest := markdownBlocks(item[1:])
+			b.WriteString("<li>" + markdownInline(strings.TrimSpace(item[0])) + "\n" + rest + "</li>\n")
+		}
+		item = nil
+	}
+
+	// Items that are indented as far as the content of the current item
+	// are nested in it.
+	content := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if m := itemRe.FindString(line); m != "" && (len(item) == 0 || indent(line) < content) {
+			writeItem()
+			item = append(item, line[len(m):])
+			content = len(m)
+			continue
+		}
+
+		// Indented lines belong to the current item, and blank lines only
+		// end the list if what follows isn't part of it.
+		if strings.TrimSpace(line) == "" {
+			if i+1 < len(lines) && (itemRe.MatchString(lines[i+1]) || isIndentedCode(lines[i+1]) || strings.HasPrefix(lines[i+1], "  ")) {
+				continue
+			}
+			break
+		}
+		if strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t") {
+			item = append(item, strings.TrimLeft(line, " \t"))
+			continue
+		}
+		if len(item) > 0 && !onlyActionsRe.MatchString(line) {
+			// A lazy continuation of the item's text.
+			item[len(item)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		break
+	}
+	writeItem()
+
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+var (
+	autolinkRe  = regexp.MustCompile(`^<((?:https?|mailto):[^\s<>]+)>`)
+	inlineTagRe = regexp.MustCompile(`^</?[A-Za-z][A-Za-z0-9-]*(?:\s+[^<>]*)?/?>`)
+	entityRe    = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	linkDestRe  = regexp.MustCompile(`^\(\s*(<[^>]*>|[^\s()]*)(?:\s+"([^"]*)")?\s*\)`)
+)
+
+// markdownInline converts the inline Markdown in s to HTML.
+func markdownInline(s string) string {
+	b := &strings.Builder{}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!<>&|~\"'", s[i+1]) >= 0:
+			b.WriteString(escapeText(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+			continue
+
+		case c == ' ' && strings.HasPrefix(s[i:], "  \n"):
+			b.WriteString("<br>\n")
+			i += 3
+			continue
+
+		case c == '`':
+			n := 0
+			for i+n < len(s) && s[i+n] == '`' {
+				n++
+			}
+			fence := s[i : i+n]
+			if end := strings.Index(s[i+n:], fence); end >= 0 {
+				code := strings.TrimSpace(strings.ReplaceAll(s[i+n:i+n+end], "\n", " "))
+				b.WriteString("<code>" + escapeText(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+			b.WriteString(fence)
+			i += n
+			continue
+
+		case c == '!' && strings.HasPrefix(s[i:], "!["):
+			if text, dest, title, n, ok := parseLink(s[i+1:]); ok {
+				fmt.Fprintf(b, `<img src="%s" alt="%s"`, escapeAttr(dest), escapeAttr(text))
+				if title != "" {
+					fmt.Fprintf(b, ` title="%s"`, escapeAttr(title))
+				}
+				b.WriteString(">")
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if text, dest, title, n, ok := parseLink(s[i:]); ok {
+				fmt.Fprintf(b, `<a href="%s"`, escapeAttr(dest))
+				if title != "" {
+					fmt.Fprintf(b, ` title="%s"`, escapeAttr(title))
+				}
+				b.WriteString(">" + markdownInline(text) + "</a>")
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if n, ok := writeEmphasis(b, s, i); ok {
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if m := autolinkRe.FindStringSubmatch(s[i:]); m != nil {
+				fmt.Fprintf(b, `<a href="%s">%s</a>`, escapeAttr(m[1]), escapeText(strings.TrimPrefix(m[1], "mailto:")))
+				i += len(m[0])
+				continue
+			}
+			if m := inlineTagRe.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+
+		case c == '&':
+			if m := entityRe.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+		}
+
+		b.WriteString(escapeText(s[i : i+1]))
+		i++
+	}
+
+	return b.String()
+}
+
+// parseLink parses a link of the form [text](dest "title") at the start of
+// s, and returns the number of bytes it spans.
+func parseLink(s string) (text, dest, title string, n int, ok bool) {
+	depth := 0
+	end := -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+		if end >= 0 {
+			break
+		}
+	}
+	if end < 0 {
+		return "", "", "", 0, false
+	}
+
+	m := linkDestRe.FindStringSubmatch(s[end+1:])
+	if m == nil {
+		return "", "", "", 0, false
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(m[1], "<"), ">")
+	return s[1:end], dest, m[2], end + 1 + len(m[0]), true
+}
+
+// writeEmphasis writes the emphasis that starts at s[i], and returns the
+// number of bytes it spans. Underscores inside words, as in snake_case,
+// aren't emphasis.
+func writeEmphasis(b *strings.Builder, s string, i int) (int, bool) {
+	c := s[i]
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0, false
+	}
+
+	for _, n := range []int{2, 1} {
+		delim := strings.Repeat(string(c), n)
+		if !strings.HasPrefix(s[i:], delim) || i+n >= len(s) || s[i+n] == ' ' {
+			continue
+		}
+
+		// Find a closing delimiter that isn't preceded by a space.
+		for j := i + n + 1; j+n <= len(s); j++ {
+			if s[j:j+n] != delim || s[j-1] == ' ' {
+				continue
+			}
+			if n == 1 && j+1 < len(s) && s[j+1] == c {
+				// Part of a longer run, which closes strong emphasis.
+				j++
+				continue
+			}
+			if c == '_' && j+n < len(s) && isWordByte(s[j+n]) {
+				continue
+			}
+
+			tag := "em"
+			if n == 2 {
+				tag = "strong"
+			}
+			b.WriteString("<" + tag + ">" + markdownInline(s[i+n:j]) + "</" + tag + ">")
+			return j + n - i, true
+		}
+	}
+	return 0, false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// escapeText escapes the characters that can't appear in HTML text.
+func escapeText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// escapeAttr escapes the characters that can't appear in a quoted HTML
+// attribute.
+func escapeAttr(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;").Replace(s)
+}
@@ -0,0 +1,100 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Markdown(t *testing.T) {
+	t.Parallel()
+
+	render := turbo.New(turbo.Options{
+		Directory:  "fixtures/markdown",
+		Layout:     "layout",
+		Processors: map[string][]turbo.Processor{".md": turbo.MarkdownProcessors},
+	})
+
+	binding := struct {
+		Name   string
+		Topics []string
+	}{"<Turbo>", []string{"Install", "Deploy"}}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "front matter layout",
+			template: "docs/help",
+			expected: `<docs title="Getting help"><h1>Help for &lt;Turbo&gt;</h1>` + "\n" +
+				`<p>Read the <em>docs</em> or <a href="/support" title="Support">ask us</a>.</p>` + "\n" +
+				"\n<ul>\n<li>Install</li>\n</ul>\n\n<ul>\n<li>Deploy</li>\n</ul>\n\n</docs>",
+		},
+		{
+			name:     "TOML front matter",
+			template: "about",
+			expected: "<title>About</title><p>About <strong>us</strong>.</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			if err := render.HTML(res, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, tt.template, binding); err != nil {
+				t.Fatalf("unexpected error rendering template: %v", err)
+			}
+			if body := res.Body.String(); body != tt.expected {
+				t.Fatalf("expected\n%q\nbut got\n%q", tt.expected, body)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"paragraph", "one\ntwo", "<p>one\ntwo</p>\n"},
+		{"escaping", "a < b & c &amp; d", "<p>a &lt; b &amp; c &amp; d</p>\n"},
+		{"emphasis", "*a* **b** _c_ snake_case_name", "<p><em>a</em> <strong>b</strong> <em>c</em> snake_case_name</p>\n"},
+		{"code span", "use `<b>` here", "<p>use <code>&lt;b&gt;</code> here</p>\n"},
+		{"image", `![logo](/logo.png "Logo")`, `<p><img src="/logo.png" alt="logo" title="Logo"></p>` + "\n"},
+		{"autolink", "<https://example.com>", `<p><a href="https://example.com">https://example.com</a></p>` + "\n"},
+		{"inline html", "a <kbd>b</kbd>", "<p>a <kbd>b</kbd></p>\n"},
+		{"hard break", "a  \nb", "<p>a<br>\nb</p>\n"},
+		{"thematic break", "a\n\n***\n\nb", "<p>a</p>\n<hr>\n<p>b</p>\n"},
+		{"block quote", "> a\n> b", "<blockquote>\n<p>a\nb</p>\n</blockquote>\n"},
+		{"ordered list", "3. a\n4. b", "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{"nested list", "- a\n  - b\n- c", "<ul>\n<li>a\n<ul>\n<li>b</li>\n</ul>\n</li>\n<li>c</li>\n</ul>\n"},
+		{"fenced code", "```go\nif a < b {\n```", "<pre><code class=\"language-go\">if a &lt; b {\n</code></pre>\n"},
+		{"indented code", "    x := 1\n\n    y := 2", "<pre><code>x := 1\n\ny := 2\n</code></pre>\n"},
+		{"html block", "<div>\n*raw*\n</div>", "<div>\n*raw*\n</div>\n"},
+		{"actions", `{{ if .A }}` + "\n\n" + `Hi {{ .Name | printf "%q" }}` + "\n\n{{ end }}", "{{ if .A }}\n<p>Hi {{ .Name | printf \"%q\" }}</p>\n{{ end }}\n"},
+		{"action in link", "[x]({{ .URL }})", `<p><a href="{{ .URL }}">x</a></p>` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &turbo.Source{
+				Body:       []byte(tt.input),
+				LeftDelim:  turbo.DefaultLeftDelim,
+				RightDelim: turbo.DefaultRightDelim,
+			}
+			if err := turbo.Markdown(src); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual := string(src.Body); actual != tt.expected {
+				t.Fatalf("expected\n%q\nbut got\n%q", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package turbo
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// RevisionEnv is the environment variable that is checked for the revision
+// of the running build when the binary wasn't built with VCS information.
+const RevisionEnv = "TURBO_REVISION"
+
+// Revision and BuildTime can be set at link time, ie,
+//
+//	go build -ldflags "-X github.com/bentranter/turbo.Revision=$(git rev-parse HEAD) -X github.com/bentranter/turbo.BuildTime=$(date -u +%FT%TZ)"
+//
+// Revision is only used for builds that don't have VCS information embedded
+// by the Go toolchain. BuildTime is parsed as RFC 3339. Without it, the time
+// the binary was last modified is used instead.
+var (
+	Revision  string
+	BuildTime string
+)
+
+type meta struct {
+	revision  string
+	dirty     bool
+	buildTime time.Time
+}
+
+// gatherMeta figures out which revision of the app is running. In order, it
+// checks the VCS information embedded by the Go toolchain, the RevisionEnv
+// environment variable, the Revision variable, and finally asks git.
+func (r *Render) gatherMeta() {
+	m := &meta{buildTime: buildTime()}
+
+	if !m.fromBuildInfo() && !m.fromEnv() && !m.fromLinker() {
+		m.fromGit()
+	}
+
+	r.m = m
+}
+
+func (m *meta) fromBuildInfo() bool {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return false
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			m.revision = setting.Value
+		case "vcs.modified":
+			m.dirty = setting.Value == "true"
+		}
+	}
+	return m.revision != ""
+}
+
+func (m *meta) fromEnv() bool {
+	m.revision = strings.TrimSpace(os.Getenv(RevisionEnv))
+	return m.revision != ""
+}
+
+func (m *meta) fromLinker() bool {
+	m.revision = strings.TrimSpace(Revision)
+	return m.revision != ""
+}
+
+func (m *meta) fromGit() {
+	// Not every environment has git installed, so failures are silent and
+	// just leave the revision empty.
+	m.revision = git("rev-parse", "HEAD")
+	if m.revision == "" {
+		return
+	}
+
+	m.dirty = git("status", "--porcelain") != ""
+}
+
+// buildTime returns the time the running binary was built, going by the
+// BuildTime variable or the modification time of the executable. It is the
+// zero time if neither is known.
+func buildTime() time.Time {
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(BuildTime)); err == nil {
+		return t
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return time.Time{}
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// git runs git with the given arguments, and returns its trimmed output.
+func git(args ...string) string {
+	cmd := exec.Command("git", args...)
+	buf := &bytes.Buffer{}
+	cmd.Stdout = buf
+
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// Revision returns the VCS revision of the running build.
+func (r *Render) Revision() string {
+	return r.m.revision
+}
+
+// Dirty reports whether the running build had uncommitted changes.
+func (r *Render) Dirty() bool {
+	return r.m.dirty
+}
+
+// BuildTime returns the time the running binary was built. It is the zero time
+// if it is unknown.
+func (r *Render) BuildTime() time.Time {
+	return r.m.buildTime
+}
@@ -0,0 +1,65 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Revision(t *testing.T) {
+	t.Setenv(turbo.RevisionEnv, "  abc123\n")
+
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/meta",
+		Layout:    "layout",
+	})
+
+	if revision := render.Revision(); revision != "abc123" {
+		t.Fatalf("expected revision %s but got %q", "abc123", revision)
+	}
+
+	t.Run("render revision helpers", func(t *testing.T) {
+		const expected = `abc123:abc123:test`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+}
+
+func TestRender_BuildTime(t *testing.T) {
+	t.Run("set at link time", func(t *testing.T) {
+		defer func(v string) { turbo.BuildTime = v }(turbo.BuildTime)
+		turbo.BuildTime = "2024-01-02T03:04:05Z"
+
+		render := turbo.New(turbo.Options{Directory: "fixtures/meta"})
+		if expected := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !render.BuildTime().Equal(expected) {
+			t.Fatalf("expected build time %s but got %s", expected, render.BuildTime())
+		}
+	})
+
+	t.Run("modification time of the binary", func(t *testing.T) {
+		exe, err := os.Executable()
+		if err != nil {
+			t.Skipf("executable not found: %v", err)
+		}
+		info, err := os.Stat(exe)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		render := turbo.New(turbo.Options{Directory: "fixtures/meta"})
+		if !render.BuildTime().Equal(info.ModTime()) {
+			t.Fatalf("expected build time %s but got %s", info.ModTime(), render.BuildTime())
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package turbo
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// Minify configures the minification of HTML. Runs of whitespace are
+// collapsed to a single space and comments are removed. The contents of pre,
+// textarea, script and style elements, and of attribute values, are left
+// alone.
+type Minify struct {
+	// Precompile minifies the template sources when they are compiled,
+	// instead of the output of every render. Template actions are left
+	// untouched, but whitespace that they output is not collapsed.
+	Precompile bool
+
+	// Exclude are patterns, as matched by path.Match, for the names of
+	// templates that aren't minified, ie, "emails/*". When minifying
+	// output, the name of the view is matched, not its layout.
+	Exclude []string
+}
+
+// includes reports whether the template with the given name is minified.
+func (m *Minify) includes(name string) bool {
+	for _, pattern := range m.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// minifyOutput minifies the rendered output of the template with the given
+// name in place, unless it was minified when it was compiled.
+func (r *Render) minifyOutput(buf *bytes.Buffer, name string) {
+	m := r.opt.Minify
+	if m == nil || m.Precompile || !m.includes(name) {
+		return
+	}
+
+	out := minifyHTML(buf.Bytes(), "", "")
+	buf.Reset()
+	buf.Write(out)
+}
+
+// minifySource minifies the source of the template with the given name, if
+// templates are minified when they are compiled.
+func (r *Render) minifySource(buf []byte, name, leftDelim, rightDelim string) []byte {
+	m := r.opt.Minify
+	if m == nil || !m.Precompile || !m.includes(name) {
+		return buf
+	}
+	return minifyHTML(buf, leftDelim, rightDelim)
+}
+
+// rawTextElements are the elements whose contents are never minified.
+var rawTextElements = []string{"pre", "textarea", "script", "style"}
+
+// MinifyHTML minifies the given HTML, as Options.Minify does. Since it keeps
+// whitespace that matters, turbotest also uses it to compare HTML.
+func MinifyHTML(html string) string {
+	return string(minifyHTML([]byte(html), "", ""))
+}
+
+// minifyHTML minifies the given HTML. If the delimiters are given, template
+// actions are copied as they are.
+func minifyHTML(src []byte, leftDelim, rightDelim string) []byte {
+	out := make([]byte, 0, len(src))
+	s := string(src)
+
+	var quote byte
+	inTag := false
+	space := false
+	// Quotes only start an attribute value right after an =, so a stray
+	// apostrophe in a tag doesn't swallow the rest of the page.
+	inValue := false
+
+	for i := 0; i < len(s); {
+		// Template actions are copied verbatim, wherever they are.
+		if leftDelim != "" && strings.HasPrefix(s[i:], leftDelim) {
+			end := strings.Index(s[i+len(leftDelim):], rightDelim)
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + len(leftDelim) + len(rightDelim)
+			}
+			out, space = flushSpace(out, space)
+			out = append(out, s[i:end]...)
+			i = end
+			inValue = false
+			continue
+		}
+
+		c := s[i]
+
+		// Attribute values are copied verbatim.
+		if quote != 0 {
+			out = append(out, c)
+			if c == quote {
+				quote = 0
+			}
+			i++
+			continue
+		}
+
+		if isSpace(c) {
+			space = true
+			i++
+			continue
+		}
+
+		if inTag {
+			switch {
+			case (c == '"' || c == '\'') && inValue:
+				quote = c
+			case c == '>':
+				inTag = false
+				// Whitespace before the end of a tag is never needed.
+				space = false
+			}
+			inValue = c == '='
+			out, space = flushSpace(out, space)
+			out = append(out, c)
+			i++
+			continue
+		}
+
+		if c == '<' {
+			if strings.HasPrefix(s[i:], "<!--") {
+				end := strings.Index(s[i+4:], "-->")
+				if end < 0 {
+					i = len(s)
+				} else {
+					i += 4 + end + 3
+				}
+				continue
+			}
+
+			if name, ok := rawTextElement(s[i:]); ok {
+				out, space = flushSpace(out, space)
+				end := indexFold(s[i:], "</"+name)
+				if end < 0 {
+					// The element is closed somewhere else, like in the
+					// layout of a partial, so the rest is all its contents.
+					return append(out, s[i:]...)
+				}
+				out = append(out, s[i:i+end]...)
+				i += end
+				inTag = true
+				out = append(out, s[i:i+2+len(name)]...)
+				i += 2 + len(name)
+				continue
+			}
+
+			// A < that doesn't start a tag, like in a<b, is just text.
+			inTag = startsTag(s[i+1:])
+		}
+
+		out, space = flushSpace(out, space)
+		out = append(out, c)
+		i++
+	}
+
+	return bytes.TrimSpace(out)
+}
+
+// startsTag reports whether the text after a < makes it the start of a tag.
+func startsTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	c := s[0]
+	return c == '/' || c == '!' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// flushSpace writes the single space that a run of whitespace collapses to.
+func flushSpace(out []byte, space bool) ([]byte, bool) {
+	if space && len(out) > 0 {
+		out = append(out, ' ')
+	}
+	return out, false
+}
+
+// rawTextElement reports whether s starts with the start tag of an element
+// whose contents are left alone, and returns its name.
+func rawTextElement(s string) (string, bool) {
+	for _, name := range rawTextElements {
+		if len(s) <= len(name)+1 || !strings.EqualFold(s[1:len(name)+1], name) {
+			continue
+		}
+		if c := s[len(name)+1]; c == '>' || c == '/' || isSpace(c) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// indexFold is strings.Index, ignoring ASCII case. The substring must be
+// lower case.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
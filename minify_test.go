@@ -0,0 +1,128 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Minify(t *testing.T) {
+	page := struct {
+		Title string
+		Body  string
+	}{"Hello   world", "a\n\nb"}
+
+	tests := []struct {
+		name     string
+		minify   *turbo.Minify
+		template string
+		partial  bool
+		expected string
+	}{
+		{
+			name:     "output",
+			minify:   &turbo.Minify{},
+			template: "content",
+			expected: "<!DOCTYPE html> <html> <head> <style>\n      body  { margin: 0; }\n    </style> </head> <body class=\"page\"> " +
+				"<h1 title=\"two  spaces\"> Hello world </h1> <PRE>\n  keep\n    this\n</PRE> <textarea name=\"body\">  a\n\nb  </textarea> <script>\n  if (a  <  b) { go(); }\n</script> </body> </html>",
+		},
+		{
+			name:     "precompile",
+			minify:   &turbo.Minify{Precompile: true},
+			template: "content",
+			expected: "<!DOCTYPE html> <html> <head> <style>\n      body  { margin: 0; }\n    </style> </head> <body class=\"page\"> " +
+				"<h1 title=\"two  spaces\"> Hello   world </h1> <PRE>\n  keep\n    this\n</PRE> <textarea name=\"body\">  a\n\nb  </textarea> <script>\n  if (a  <  b) { go(); }\n</script> </body> </html>",
+		},
+		{
+			name:     "excluded",
+			minify:   &turbo.Minify{Exclude: []string{"emails/*"}},
+			template: "emails/welcome",
+			partial:  true,
+			expected: "<p>\n  Hello\n</p>\n",
+		},
+		{
+			name:     "excluded when precompiled",
+			minify:   &turbo.Minify{Precompile: true, Exclude: []string{"emails/*"}},
+			template: "emails/welcome",
+			partial:  true,
+			expected: "<p>\n  Hello\n</p>\n",
+		},
+		{
+			name:     "unclosed raw text element",
+			minify:   &turbo.Minify{},
+			template: "_pre",
+			partial:  true,
+			expected: "<p>a</p> <pre>  unterminated\n  text",
+		},
+		{
+			name:     "unclosed raw text element when precompiled",
+			minify:   &turbo.Minify{Precompile: true},
+			template: "_pre",
+			partial:  true,
+			expected: "<p>a</p> <pre>  unterminated\n  text",
+		},
+		{
+			name:     "partial",
+			minify:   &turbo.Minify{},
+			template: "emails/welcome",
+			partial:  true,
+			expected: "<p> Hello </p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			render := turbo.New(turbo.Options{
+				Directory: "fixtures/minify",
+				Layout:    "layout",
+				Minify:    tt.minify,
+			})
+
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if err := render.HTML(res, req, http.StatusOK, tt.template, page, tt.partial); err != nil {
+				t.Fatalf("unexpected error rendering template: %v", err)
+			}
+			if body := res.Body.String(); body != tt.expected {
+				t.Fatalf("expected\n%q\nbut got\n%q", tt.expected, body)
+			}
+
+			s, err := render.String(httptest.NewRecorder(), req, tt.template, page, tt.partial)
+			if err != nil {
+				t.Fatalf("unexpected error rendering string: %v", err)
+			}
+			if s != tt.expected {
+				t.Fatalf("expected String to match HTML but got %q", s)
+			}
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "less than in text",
+			html:     "<p>if a<b then it's ok</p>  <!-- note -->  <pre> a  b </pre>  <p>  q  </p>",
+			expected: "<p>if a<b then it's ok</p> <pre> a  b </pre> <p> q </p>",
+		},
+		{
+			name:     "quotes outside of attribute values",
+			html:     "<p title = 'a  b'  data-x=\"c  d\" it's>  a  </p>  <p>  b  </p>",
+			expected: "<p title = 'a  b' data-x=\"c  d\" it's> a </p> <p> b </p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := turbo.MinifyHTML(tt.html); actual != tt.expected {
+				t.Fatalf("expected\n%q\nbut got\n%q", tt.expected, actual)
+			}
+		})
+	}
+}
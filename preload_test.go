@@ -0,0 +1,138 @@
+package turbo_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Preload(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/preload",
+		Layout:    "layout",
+		Preloads: []turbo.Preload{
+			{URL: "/app.css", As: "style"},
+		},
+	})
+
+	t.Run("preloads are sent as Link headers", func(t *testing.T) {
+		const expected = `head<p>test</p>foot`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+		links := res.Header()["Link"]
+		if len(links) != 2 {
+			t.Fatalf("expected 2 Link headers but got %v", links)
+		}
+		if links[0] != `</app.css>; rel=preload; as=style` {
+			t.Fatalf("expected Link header from options but got %s", links[0])
+		}
+		if links[1] != `</app.js>; rel=preload; as=script` {
+			t.Fatalf("expected Link header from template but got %s", links[1])
+		}
+	})
+
+	t.Run("preloads are not added to partials", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test", true); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+
+		if links := res.Header()["Link"]; len(links) != 0 {
+			t.Fatalf("expected no Link headers but got %v", links)
+		}
+	})
+}
+
+func TestRender_EarlyHints(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory:  "fixtures/preload",
+		Layout:     "layout",
+		EarlyHints: true,
+		Preloads: []turbo.Preload{
+			{URL: "/app.css", As: "style"},
+		},
+	})
+	h := turbo.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		render.HTML(w, r, http.StatusOK, "content", "test")
+	}))
+
+	assertEarlyHints := func(t *testing.T, client *http.Client, url string, proto int) {
+		t.Helper()
+
+		var hints []textproto.MIMEHeader
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				if code == http.StatusEarlyHints {
+					hints = append(hints, header)
+				}
+				return nil
+			},
+		}
+
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, url, nil)
+		req.Header.Set("Turbolinks-Referrer", url)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error performing request: %v", err)
+		}
+		res.Body.Close()
+
+		if res.ProtoMajor != proto {
+			t.Fatalf("expected HTTP/%d response but got %s", proto, res.Proto)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusOK, res.StatusCode)
+		}
+		if len(hints) != 1 {
+			t.Fatalf("expected 1 early hints response but got %d", len(hints))
+		}
+		if link := hints[0].Get("Link"); link != `</app.css>; rel=preload; as=style` {
+			t.Fatalf("expected Link header in early hints but got %s", link)
+		}
+	}
+
+	t.Run("HTTP/1.1", func(t *testing.T) {
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		assertEarlyHints(t, srv.Client(), srv.URL, 1)
+	})
+
+	t.Run("HTTP/2", func(t *testing.T) {
+		srv := httptest.NewUnstartedServer(h)
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+		defer srv.Close()
+
+		assertEarlyHints(t, srv.Client(), srv.URL, 2)
+	})
+
+	t.Run("recorder", func(t *testing.T) {
+		// The recorder keeps the first status it's given, and then fails
+		// to write the body, which is why EarlyHints is only for writers
+		// that send 1xx responses.
+		res := httptest.NewRecorder()
+		render.HTML(res, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "content", "test")
+
+		if res.Code != http.StatusEarlyHints {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusEarlyHints, res.Code)
+		}
+		if link := res.Header().Get("Link"); link != `</app.css>; rel=preload; as=style` {
+			t.Fatalf("expected Link header but got %s", link)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package turbo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Source is the source of a template, as it goes through the processors of
+// its extension before it is parsed.
+type Source struct {
+	// Name is the name of the template, and Ext is the extension of its
+	// file, ie, ".md".
+	Name string
+	Ext  string
+
+	// LeftDelim and RightDelim are the delimiters of the template's
+	// actions, which processors must leave alone.
+	LeftDelim  string
+	RightDelim string
+
+	// Body is the source of the template.
+	Body []byte
+
+	// FrontMatter is the metadata at the top of the file, once the
+	// FrontMatter processor has removed it from the body. The layout key
+	// picks the layout that the template is rendered in.
+	FrontMatter map[string]interface{}
+}
+
+// Processor transforms the source of a template before it is parsed.
+type Processor func(src *Source) error
+
+// MarkdownProcessors compile Markdown files into templates. Use them with
+// Options.Processors to render .md files as views:
+//
+//	turbo.New(turbo.Options{
+//		Processors: map[string][]turbo.Processor{".md": turbo.MarkdownProcessors},
+//	})
+var MarkdownProcessors = []Processor{FrontMatter, Markdown}
+
+// FrontMatter removes the front matter from the top of the source, and
+// stores it in Source.FrontMatter. The front matter is either simple
+// "key: value" lines between "---" lines, or TOML between "+++" lines.
+func FrontMatter(src *Source) error {
+	var fence string
+	switch {
+	case bytes.HasPrefix(src.Body, []byte("---\n")), bytes.HasPrefix(src.Body, []byte("---\r\n")):
+		fence = "---"
+	case bytes.HasPrefix(src.Body, []byte("+++\n")), bytes.HasPrefix(src.Body, []byte("+++\r\n")):
+		fence = "+++"
+	default:
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(src.Body), "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == fence {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return fmt.Errorf("front matter is never closed with %s", fence)
+	}
+
+	header := strings.Join(lines[1:end], "")
+	var err error
+	if fence == "+++" {
+		src.FrontMatter, err = parseTOML(header)
+	} else {
+		src.FrontMatter, err = parseFrontMatter(header)
+	}
+	if err != nil {
+		return fmt.Errorf("front matter: %v", err)
+	}
+
+	src.Body = []byte(strings.Join(lines[end+1:], ""))
+	return nil
+}
+
+// parseFrontMatter parses "key: value" lines. Values are parsed like TOML
+// values, so strings can be quoted, and anything else is kept as a string.
+func parseFrontMatter(header string) (map[string]interface{}, error) {
+	meta := make(map[string]interface{})
+	for n, line := range strings.Split(header, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value but got %q", n+1, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if v, err := parseTOMLValue(value); err == nil {
+			meta[key] = v
+		} else {
+			meta[key] = value
+		}
+	}
+	return meta, nil
+}
+
+// process runs the source of the template with the given name through the
+// processors of its extension.
+func (r *Render) process(name, ext string, buf []byte) (*Source, error) {
+	left, right := r.delims(name)
+	src := &Source{
+		Name:       name,
+		Ext:        ext,
+		LeftDelim:  left,
+		RightDelim: right,
+		Body:       buf,
+	}
+
+	for _, p := range r.opt.Processors[ext] {
+		if err := p(src); err != nil {
+			return nil, fmt.Errorf("template: %s: %v", name, err)
+		}
+	}
+	return src, nil
+}
+
+// frontMatter returns the front matter of the template with the given name,
+// which is empty if it has none.
+func (rc *renderContext) frontMatter(name string) map[string]interface{} {
+	if fm, ok := rc.meta[name]; ok {
+		return fm
+	}
+	return map[string]interface{}{}
+}
+
+// layout returns the layout that the template with the given name is
+// rendered in, from its front matter or from Options.Layout.
+func (r *Render) layout(rc *renderContext, name string) string {
+	if layout, ok := rc.meta[name]["layout"].(string); ok {
+		return layout
+	}
+	return r.opt.Layout
+}
@@ -0,0 +1,150 @@
+package turbo
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// Preload is a critical asset that the browser should start fetching before
+// it receives the body of the page.
+type Preload struct {
+	// URL is the location of the asset.
+	URL string
+
+	// As is the type of the asset, ie, "style", "script", "font" or
+	// "image".
+	As string
+}
+
+// String formats the preload as the value of a Link header.
+func (p Preload) String() string {
+	link := "<" + p.URL + ">; rel=preload"
+	if p.As != "" {
+		link += "; as=" + p.As
+	}
+	if p.As == "font" {
+		// Fonts are always fetched in CORS mode, so the preload is ignored
+		// unless it is too.
+		link += "; crossorigin"
+	}
+	return link
+}
+
+// addPreloadHeaders adds a Link header for each of the given preloads,
+// skipping the ones that are already present.
+func addPreloadHeaders(header http.Header, preloads ...Preload) {
+	for _, p := range preloads {
+		link := p.String()
+
+		present := false
+		for _, v := range header["Link"] {
+			if v == link {
+				present = true
+				break
+			}
+		}
+		if !present {
+			header.Add("Link", link)
+		}
+	}
+}
+
+// stream renders the given template inside of the given layout, flushing the
+// layout up to the call to yield before the view is rendered.
+func (r *Render) stream(w http.ResponseWriter, req *http.Request, rc *renderContext, status int, name, layout string, binding interface{}) (int, error) {
+	sw := &streamWriter{
+		w:        w,
+		status:   status,
+		preloads: r.opt.Preloads,
+		buf:      &bytes.Buffer{},
+	}
+
+	// Replace the buffered yield with one that flushes everything rendered
+	// so far, and then executes the view straight to the response.
+	rc.templates.Funcs(template.FuncMap{
+		"yield": func() (template.HTML, error) {
+			sw.Flush()
+			return "", r.instrument(rc, RenderYield, name, "", func() (int, error) {
+				n := sw.n
+				err := rc.templates.ExecuteTemplate(&ctxWriter{rc: rc, w: sw}, name, binding)
+				return sw.n - n, err
+			})
+		},
+	})
+
+	err := rc.templates.ExecuteTemplate(&ctxWriter{rc: rc, w: sw}, layout, binding)
+	if err != nil && !sw.flushed {
+		// Nothing has been sent yet, so we can still respond as HTML does.
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return sw.n, err
+	}
+	if err != nil {
+		// The status code is long gone, so the best we can do is tell the
+		// user something went wrong where the rest of the page should be.
+		r.writeStreamError(sw, err)
+	}
+
+	sw.Flush()
+	return sw.n, err
+}
+
+// writeStreamError writes the fragment that replaces the remainder of a page
+// that failed to render after it was flushed.
+func (r *Render) writeStreamError(w io.Writer, err error) {
+	if r.opt.StreamError != nil {
+		r.opt.StreamError(w, err)
+		return
+	}
+
+	// Only leak the error to the page while developing.
+	message := "Something went wrong while rendering this page."
+	if r.opt.IsDevelopment {
+		message = err.Error()
+	}
+	io.WriteString(w, `<div class="turbo-error" role="alert">`+template.HTMLEscapeString(message)+`</div>`)
+}
+
+// streamWriter buffers writes until it is flushed for the first time, after
+// which it writes directly to the underlying response writer.
+type streamWriter struct {
+	w        http.ResponseWriter
+	status   int
+	preloads []Preload
+	buf      *bytes.Buffer
+	flushed  bool
+
+	// n is the number of bytes written so far.
+	n int
+}
+
+// Write buffers the write if the response hasn't been flushed yet, and
+// writes it to the response otherwise.
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	sw.n += len(b)
+	if !sw.flushed {
+		return sw.buf.Write(b)
+	}
+	return sw.w.Write(b)
+}
+
+// Flush sends the headers and anything that has been buffered so far, and
+// flushes the underlying response writer if it supports it.
+func (sw *streamWriter) Flush() {
+	if !sw.flushed {
+		sw.flushed = true
+
+		header := sw.w.Header()
+		addPreloadHeaders(header, sw.preloads...)
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", ContentHTML)
+		}
+		sw.w.WriteHeader(sw.status)
+		sw.buf.WriteTo(sw.w)
+	}
+
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
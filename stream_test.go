@@ -0,0 +1,60 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Stream(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/stream",
+		Layout:    "layout",
+		Stream:    true,
+		Preloads: []turbo.Preload{
+			{URL: "/app.css", As: "style"},
+		},
+	})
+
+	t.Run("stream template without errors", func(t *testing.T) {
+		const expected = `head<p>test</p>foot`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error streaming template: %v", err)
+		}
+
+		if !res.Flushed {
+			t.Fatalf("expected response to be flushed")
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+		if link := res.Header().Get("Link"); link != `</app.css>; rel=preload; as=style` {
+			t.Fatalf("expected Link header for preload but got %s", link)
+		}
+	})
+
+	t.Run("stream template with errors after flush", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "broken", "test"); err == nil {
+			t.Fatalf("expected error streaming broken template but got none")
+		}
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected HTTP status %d but got %d", http.StatusOK, res.Code)
+		}
+		body := res.Body.String()
+		if !strings.HasPrefix(body, "head") {
+			t.Fatalf("expected the layout head to be flushed but got %s", body)
+		}
+		if !strings.Contains(body, `class="turbo-error"`) {
+			t.Fatalf("expected error fragment in body but got %s", body)
+		}
+	})
+}
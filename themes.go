@@ -0,0 +1,157 @@
+package turbo
+
+import (
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	texttemplate "text/template"
+)
+
+// templateSet is a compiled set of templates, for the base templates or for
+// one of the themes.
+type templateSet struct {
+	// templates is never executed itself. Each render executes a clone of
+	// it from the clones pool instead, so that the funcs bound to one
+	// request are never seen by another.
+	templates *template.Template
+	clones    *sync.Pool
+
+	// texts are the plain text parts of emails, parsed as text.
+	texts *texttemplate.Template
+
+	// sums are the hashes of the source of each template, for fragment
+	// cache keys, and meta is the front matter of the templates that have
+	// any.
+	sums map[string]string
+	meta map[string]map[string]interface{}
+}
+
+// templateRoot is a directory of templates. dir is set for the root from
+// Options.Directory, so that the paths of its templates are the same as the
+// paths on disk.
+type templateRoot struct {
+	dir  string
+	fsys fs.FS
+}
+
+// roots returns the template roots of the given theme, in order of
+// precedence.
+func (r *Render) roots(theme string) []templateRoot {
+	var roots []templateRoot
+	if r.opt.Directory != "" {
+		roots = append(roots, templateRoot{dir: r.opt.Directory, fsys: os.DirFS(r.opt.Directory)})
+	}
+	for _, fsys := range r.opt.Roots {
+		roots = append(roots, templateRoot{fsys: fsys})
+	}
+	if theme != "" {
+		for _, fsys := range r.opt.Themes[theme] {
+			roots = append(roots, templateRoot{fsys: fsys})
+		}
+	}
+	return roots
+}
+
+// themes returns the names of the themes, in order, after the base
+// templates, which are named "".
+func (r *Render) themes() []string {
+	themes := []string{""}
+	for theme := range r.opt.Themes {
+		if theme != "" {
+			themes = append(themes, theme)
+		}
+	}
+	sort.Strings(themes[1:])
+	return themes
+}
+
+// theme returns the theme selected for the request, or "" for the base
+// templates.
+func (r *Render) theme(req *http.Request) string {
+	if r.opt.Theme == nil {
+		return ""
+	}
+	if theme := r.opt.Theme(req); theme != "" {
+		if _, ok := r.opt.Themes[theme]; ok {
+			return theme
+		}
+	}
+	return ""
+}
+
+// set returns the compiled templates of the given theme.
+func (r *Render) set(theme string) *templateSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sets[theme]
+}
+
+// extensions returns the extensions of the files that are compiled.
+func (r *Render) extensions() []string {
+	exts := r.opt.Extensions
+	for ext := range r.opt.Processors {
+		exts = append(exts[:len(exts):len(exts)], ext)
+	}
+	return exts
+}
+
+// walkTemplates calls fn with the name, path and contents of every template
+// of the given theme. When roots have templates with the same name, the one
+// from the last root wins, but it is visited in the place of the first.
+func (r *Render) walkTemplates(theme string, fn func(name, path string, buf []byte)) {
+	type source struct {
+		path string
+		buf  []byte
+	}
+
+	var names []string
+	sources := make(map[string]source)
+
+	for _, root := range r.roots(theme) {
+		// Walk the root and collect any valid template.
+		fs.WalkDir(root.fsys, ".", func(rel string, d fs.DirEntry, err error) error {
+			// If we encounter a directory, return immediately since we
+			// can't compile it.
+			if err != nil || d.IsDir() {
+				return nil
+			}
+
+			// Determine the file extension.
+			ext := path.Ext(rel)
+
+			// Collect each template. We check if the extension matches the
+			// allowed ones that we defined before compiling.
+			for _, extension := range r.extensions() {
+				if ext == extension {
+					buf, err := fs.ReadFile(root.fsys, rel)
+					if err != nil {
+						panic(err)
+					}
+
+					file := rel
+					if root.dir != "" {
+						file = filepath.Join(root.dir, filepath.FromSlash(rel))
+					}
+
+					name := rel[0 : len(rel)-len(ext)]
+					if _, ok := sources[name]; !ok {
+						names = append(names, name)
+					}
+					sources[name] = source{path: file, buf: buf}
+					break
+				}
+			}
+
+			return nil
+		})
+	}
+
+	for _, name := range names {
+		fn(name, sources[name].path, sources[name].buf)
+	}
+}
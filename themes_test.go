@@ -0,0 +1,98 @@
+package turbo_test
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_Themes(t *testing.T) {
+	t.Parallel()
+
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/themes/base",
+		Layout:    "layout",
+		Roots: []fs.FS{
+			fstest.MapFS{
+				"content.tmpl": {Data: []byte(`<p class="override">{{ . }}</p>`)},
+			},
+		},
+		Themes: map[string][]fs.FS{
+			"acme": {os.DirFS("fixtures/themes/acme")},
+		},
+		Theme: func(r *http.Request) string {
+			return r.Header.Get("X-Theme")
+		},
+	})
+
+	tests := []struct {
+		name     string
+		theme    string
+		expected string
+	}{
+		{"base templates", "", `<base><nav>base</nav><p class="override">hi</p></base>`},
+		{"theme overrides", "acme", `<acme><nav>acme</nav><p class="override">hi</p></acme>`},
+		{"unknown theme", "globex", `<base><nav>base</nav><p class="override">hi</p></base>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Theme", tt.theme)
+
+			if err := render.HTML(res, req, http.StatusOK, "content", "hi"); err != nil {
+				t.Fatalf("unexpected error rendering template: %v", err)
+			}
+			if body := res.Body.String(); body != tt.expected {
+				t.Fatalf("expected %s but got %s", tt.expected, body)
+			}
+		})
+	}
+
+	t.Run("roots without a directory", func(t *testing.T) {
+		render := turbo.New(turbo.Options{
+			Roots: []fs.FS{os.DirFS("fixtures/themes/base")},
+		})
+
+		s, err := render.String(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "_nav", nil)
+		if err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		if s != "<nav>base</nav>" {
+			t.Fatalf("expected <nav>base</nav> but got %s", s)
+		}
+	})
+
+	t.Run("lint", func(t *testing.T) {
+		errs := turbo.Lint(turbo.Options{
+			Directory: "fixtures/themes/base",
+			Themes: map[string][]fs.FS{
+				"acme": {
+					fstest.MapFS{
+						"layout.tmpl":  {Data: []byte(`{{ template "_footer" }}{{ yield }}`)},
+						"_unused.tmpl": {Data: []byte(`unused`)},
+					},
+				},
+			},
+		})
+
+		expected := []string{
+			`_unused.tmpl: partial "_unused" is never used`,
+			`layout.tmpl: template "_footer" is not defined`,
+		}
+		if len(errs) != len(expected) {
+			t.Fatalf("expected %d problems but got %v", len(expected), errs)
+		}
+		for i, err := range errs {
+			if actual := err.File + ": " + err.Message; actual != expected[i] {
+				t.Fatalf("expected %s but got %s", expected[i], actual)
+			}
+		}
+	})
+}
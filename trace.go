@@ -0,0 +1,65 @@
+package turbo
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"sync"
+)
+
+// Tracer starts a span for each render, in the style of OpenTelemetry. The
+// spans of yield and partials are started with the context returned for the
+// page they are rendered in, so that they are nested under it.
+//
+// Adapting an OpenTelemetry tracer takes a few lines:
+//
+//	func (t otelTracer) Start(ctx context.Context, ev turbo.RenderEvent) (context.Context, turbo.Span) {
+//		ctx, span := t.tracer.Start(ctx, "render "+ev.Kind, trace.WithAttributes(
+//			attribute.String("template", ev.Template),
+//		))
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	Start(ctx context.Context, ev RenderEvent) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. End is called with the event of the
+// finished render, including its duration and error.
+type Span interface {
+	End(ev RenderEvent)
+}
+
+// renderContext carries the state of the render in progress. Its context
+// changes as spans are started and ended, so that the helpers called from a
+// template see the context of the render that called them.
+type renderContext struct {
+	ctx context.Context
+
+	// theme is the name of the theme the render uses, and meta is the
+	// front matter of its templates.
+	theme string
+	meta  map[string]map[string]interface{}
+
+	// locale is the locale of the request, when Options.I18n is set.
+	locale string
+
+	// templates is the clone of the template set that the render executes,
+	// and clones is the pool it's returned to afterwards.
+	templates *template.Template
+	clones    *sync.Pool
+}
+
+// ctxWriter fails writes once the context of the render is done, which aborts
+// the execution of the template. This stops us from rendering pages for
+// clients that have gone away.
+type ctxWriter struct {
+	rc *renderContext
+	w  io.Writer
+}
+
+func (cw *ctxWriter) Write(b []byte) (int, error) {
+	if err := cw.rc.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(b)
+}
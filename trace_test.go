@@ -0,0 +1,78 @@
+package turbo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bentranter/turbo"
+	"github.com/bentranter/turbo/turbotest"
+)
+
+func TestRender_Tracer(t *testing.T) {
+	tracer := &turbotest.SpanRecorder{}
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/hooks",
+		Layout:    "layout",
+		Tracer:    tracer,
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+		t.Fatalf("unexpected error rendering template: %v", err)
+	}
+
+	expected := []struct {
+		id       int
+		parent   int
+		kind     string
+		template string
+	}{
+		{1, 0, turbo.RenderPage, "content"},
+		{2, 1, turbo.RenderYield, "content"},
+		{3, 1, turbo.RenderPartial, "_foot"},
+	}
+
+	spans := tracer.Spans()
+	if len(spans) != len(expected) {
+		t.Fatalf("expected %d spans but got %d: %+v", len(expected), len(spans), spans)
+	}
+	for i, span := range spans {
+		e := expected[i]
+		if span.ID != e.id || span.Parent != e.parent || span.Event.Kind != e.kind || span.Event.Template != e.template {
+			t.Fatalf("expected span %+v but got %+v", e, span)
+		}
+		if !span.Ended {
+			t.Fatalf("expected span %d to have ended", span.ID)
+		}
+	}
+}
+
+func TestRender_Context(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/hooks",
+		Layout:    "layout",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("rendering with a canceled context should error", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context canceled error but got %v", err)
+		}
+	})
+
+	t.Run("rendering to a string with a canceled context should error", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		if _, err := render.String(res, req, "content", "test", true); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context canceled error but got %v", err)
+		}
+	})
+}
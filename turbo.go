@@ -3,20 +3,23 @@
 //
 // TODO(ben)
 // Stuff we need:
-//	- tubro.CSRF for CSRF (obv)
+//   - tubro.CSRF for CSRF (obv)
 package turbo
 
 import (
 	"bytes"
-	"encoding/base64"
 	"fmt"
 	"html/template"
-	"io/ioutil"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
+	texttemplate "text/template"
 	"time"
 )
 
@@ -51,25 +54,155 @@ var helperFuncs = template.FuncMap{
 	},
 	"currentpage": func(page string) bool { return false },
 	"gitsha":      func() string { return "" },
+	"revision":    func() string { return "" },
+	"dirty":       func() bool { return false },
+	"buildtime":   func() time.Time { return time.Time{} },
 	"flash":       func() string { return "" },
+	"preload":     func(url string, as ...string) string { return "" },
+	"ctx":         func(key string) interface{} { return nil },
+	"frontmatter": func() map[string]interface{} { return nil },
+	"partial": func(name string, binding interface{}) (template.HTML, error) {
+		return "", fmt.Errorf("partial called outside of a render")
+	},
+	"cache": func(name string, binding interface{}, keys ...interface{}) (template.HTML, error) {
+		return "", fmt.Errorf("cache called outside of a render")
+	},
 }
 
 type Render struct {
-	opt       *Options
-	m         *meta
-	templates *template.Template
+	opt    *Options
+	m      *meta
+	expect map[string]reflect.Type
+
+	// sets are the compiled templates of each theme, keyed by its name.
+	// The base templates are under "".
+	mu   sync.RWMutex
+	sets map[string]*templateSet
+
+	// generations are bumped by InvalidateAll, and are part of fragment
+	// cache keys.
+	generations map[string]uint64
 }
 
 type Options struct {
-	Directory     string
-	Layout        string
-	Extensions    []string
+	Directory string
+
+	// Roots are more template directories. Templates in later roots
+	// override the templates with the same name in earlier ones, and
+	// Directory comes before all of them. Directory is only defaulted to
+	// the working directory when there are no Roots.
+	Roots []fs.FS
+
+	// Themes are roots that override the templates of Directory and Roots,
+	// keyed by the name of the theme. A theme that only has a layout and a
+	// few partials uses the base templates for everything else.
+	Themes map[string][]fs.FS
+
+	// Theme selects the theme to render a request with. The base templates
+	// are used when it returns a theme that doesn't exist, or when it is
+	// nil.
+	Theme func(r *http.Request) string
+
+	Layout     string
+	Extensions []string
+
+	// Processors transform the source of the templates with each extension
+	// before they are parsed, ie, to compile Markdown with
+	// MarkdownProcessors. Their extensions are compiled along with
+	// Extensions.
+	Processors map[string][]Processor
+
 	Funcs         []template.FuncMap
 	IsDevelopment bool
-}
 
-type meta struct {
-	gitSHA string
+	// Delims are the delimiters of template actions, for apps whose
+	// templates contain {{ }} for something else, like Vue. DirDelims
+	// override them for the templates in a directory, and its
+	// subdirectories, keyed by its path relative to the template root.
+	Delims    Delims
+	DirDelims map[string]Delims
+
+	// RequestFuncs are called on every render to build helpers that know
+	// about the current request, like the built in flash and currentpage
+	// helpers do. Each one is also called once with a placeholder request
+	// when the templates are compiled, to learn the names of its helpers.
+	RequestFuncs []func(w http.ResponseWriter, r *http.Request) template.FuncMap
+
+	// IndentJSON and IndentXML indent the output of JSON and XML.
+	IndentJSON bool
+	IndentXML  bool
+
+	// StreamingJSON encodes JSON directly to the response instead of
+	// buffering it first. Encoding errors can no longer change the status
+	// code once this is set.
+	StreamingJSON bool
+
+	// UnEscapeHTML disables the escaping of <, > and & in JSON output.
+	UnEscapeHTML bool
+
+	// Minify minifies the HTML rendered by HTML and String, or the template
+	// sources when they are compiled. Streamed pages aren't minified unless
+	// Minify.Precompile is set.
+	Minify *Minify
+
+	// EmailLayout is the layout of the emails rendered by Email. Its
+	// name.html and name.text variants are the layouts of the HTML and
+	// plain text parts.
+	EmailLayout string
+
+	// EmailInlineCSS moves the rules of the style elements in HTML emails
+	// into the style attributes of the elements they match, since many
+	// email clients ignore style elements.
+	EmailInlineCSS bool
+
+	// ETags enables conditional GET for HTML renders. Pages get a strong
+	// ETag computed from the rendered page, unless the handler already set
+	// an ETag or Last-Modified header, and requests with a matching
+	// If-None-Match or If-Modified-Since header are answered with 304 Not
+	// Modified. Streamed pages are sent before they are fully rendered, so
+	// they are never given an ETag.
+	ETags bool
+
+	// Stream enables streaming for HTML renders that use a layout. The
+	// layout is sent to the client as soon as it reaches yield, so the
+	// browser can start fetching assets while the view is rendered.
+	Stream bool
+
+	// Preloads are sent as Link headers on every HTML response that uses
+	// the layout.
+	Preloads []Preload
+
+	// EarlyHints sends the Preloads in a 103 Early Hints response before
+	// the template is rendered. Only set it when the ResponseWriter sends
+	// 1xx responses before the final one, as the writers of net/http do.
+	// Other writers, like httptest.ResponseRecorder, record the 103 as the
+	// status of the response.
+	EarlyHints bool
+
+	// Assets registers the asset_path and asset_tag template helpers.
+	Assets *Assets
+
+	// I18n registers the t, l and locale template helpers, which translate
+	// and localize in the locale of the request, and makes HTML and String
+	// prefer locale-specific templates when they exist.
+	I18n *I18n
+
+	// Hooks are called before and after every render, including the
+	// renders of yield and partials.
+	Hooks []Hook
+
+	// Cache stores the fragments rendered by the cache helper. Fragments
+	// aren't cached when it is nil.
+	Cache Cache
+
+	// Tracer starts a span for every render. Renders are also aborted
+	// when the context of the request is done.
+	Tracer Tracer
+
+	// StreamError writes the fragment that is injected into a streamed page
+	// when rendering fails after the status code has been sent. A generic
+	// error message is written when it is nil.
+	StreamError func(w io.Writer, err error)
 }
 
 func New(opts ...Options) *Render {
@@ -93,6 +226,7 @@ func New(opts ...Options) *Render {
 func (r *Render) HTML(w http.ResponseWriter, req *http.Request, status int, name string, binding interface{}, partial ...bool) error {
 	// If we're in development mode, recompile the templates.
 	if r.opt.IsDevelopment {
+		r.reloadAssets()
 		r.compileTemplatesFromDir()
 	}
 
@@ -102,26 +236,63 @@ func (r *Render) HTML(w http.ResponseWriter, req *http.Request, status int, name
 		isPartial = b
 	}
 
+	// The render is aborted if the request's context is done.
+	rc, err := r.newRender(req.Context(), r.theme(req))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	defer r.release(rc)
+	name = r.localize(rc, req, name)
+	r.addRequestFuncs(w, req, rc, name, binding, isPartial)
+
 	// Assign a layout if there is one, and if we're not rendering a partial.
 	//
 	// TODO(ben) reconsider if this would be better achieved by checking if
 	// we're getting something from the partials directory.
-	if r.opt.Layout != "" && !isPartial {
-		r.addLayoutFuncs(w, req, name, binding)
-		name = r.opt.Layout
+	if layout := r.layout(rc, name); layout != "" && !isPartial {
+		// Let the browser start fetching the critical assets while we do
+		// the rendering. HTTP/1.0 clients don't understand 1xx responses.
+		if r.opt.EarlyHints && len(r.opt.Preloads) > 0 && req.ProtoAtLeast(1, 1) {
+			addPreloadHeaders(w.Header(), r.opt.Preloads...)
+			w.WriteHeader(http.StatusEarlyHints)
+		}
+
+		if r.opt.Stream {
+			return r.instrument(rc, RenderPage, name, layout, func() (int, error) {
+				return r.stream(w, req, rc, status, name, layout, binding)
+			})
+		}
+
+		addPreloadHeaders(w.Header(), r.opt.Preloads...)
 	}
 
 	// Execute the template to an intermediate buffer to check for errors.
 	//
 	// TODO(ben) sync.Pool
 	buf := &bytes.Buffer{}
-	if err := r.templates.ExecuteTemplate(buf, name, binding); err != nil {
+	if err := r.executePage(rc, buf, name, binding, isPartial); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
 	}
+	r.minifyOutput(buf, name)
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", ContentHTML)
+	}
+
+	// Only successful responses can be cached.
+	if r.opt.ETags && status == http.StatusOK {
+		if w.Header().Get("ETag") == "" && w.Header().Get("Last-Modified") == "" {
+			w.Header().Set("ETag", strongETag(buf.Bytes()))
+		}
+		if NotModified(w, req) {
+			return nil
+		}
+	}
 
 	w.WriteHeader(status)
-	_, err := buf.WriteTo(w)
+	_, err = buf.WriteTo(w)
 	return err
 }
 
@@ -132,6 +303,7 @@ func (r *Render) HTML(w http.ResponseWriter, req *http.Request, status int, name
 func (r *Render) String(w http.ResponseWriter, req *http.Request, name string, binding interface{}, partial ...bool) (string, error) {
 	// If we're in development mode, recompile the templates.
 	if r.opt.IsDevelopment {
+		r.reloadAssets()
 		r.compileTemplatesFromDir()
 	}
 
@@ -141,20 +313,21 @@ func (r *Render) String(w http.ResponseWriter, req *http.Request, name string, b
 		isPartial = b
 	}
 
-	// Assign a layout if there is one, and if we're not rendering a partial.
-	//
-	// TODO(ben) reconsider if this would be better achieved by checking if
-	// we're getting something from the partials directory.
-	if r.opt.Layout != "" && !isPartial {
-		r.addLayoutFuncs(w, req, name, binding)
-		name = r.opt.Layout
+	// The render is aborted if the request's context is done.
+	rc, err := r.newRender(req.Context(), r.theme(req))
+	if err != nil {
+		return "", err
 	}
+	defer r.release(rc)
+	name = r.localize(rc, req, name)
+	r.addRequestFuncs(w, req, rc, name, binding, isPartial)
 
 	// TODO(ben) sync.Pool
 	buf := &bytes.Buffer{}
-	if err := r.templates.ExecuteTemplate(buf, name, binding); err != nil {
+	if err := r.executePage(rc, buf, name, binding, isPartial); err != nil {
 		return "", err
 	}
+	r.minifyOutput(buf, name)
 
 	return buf.String(), nil
 }
@@ -174,58 +347,156 @@ func (r *Render) Redirect(w http.ResponseWriter, req *http.Request, url string,
 	http.Redirect(w, req, url, http.StatusFound)
 }
 
+// executePage executes the template with the given name inside of the
+// layout, or on its own if it's a partial or there is no layout.
+func (r *Render) executePage(rc *renderContext, buf *bytes.Buffer, name string, binding interface{}, isPartial bool) error {
+	layout := r.layout(rc, name)
+	if layout == "" || isPartial {
+		return r.instrument(rc, RenderPartial, name, "", func() (int, error) {
+			err := rc.templates.ExecuteTemplate(&ctxWriter{rc: rc, w: buf}, name, binding)
+			return buf.Len(), err
+		})
+	}
+
+	return r.instrument(rc, RenderPage, name, layout, func() (int, error) {
+		err := rc.templates.ExecuteTemplate(&ctxWriter{rc: rc, w: buf}, layout, binding)
+		return buf.Len(), err
+	})
+}
+
 // TODO(ben) sync.Pool
-func (r *Render) execute(name string, binding interface{}) (*bytes.Buffer, error) {
+func (r *Render) execute(rc *renderContext, kind, name string, binding interface{}) (*bytes.Buffer, error) {
 	buf := &bytes.Buffer{}
-	return buf, r.templates.ExecuteTemplate(buf, name, binding)
+	return buf, r.instrument(rc, kind, name, "", func() (int, error) {
+		err := rc.templates.ExecuteTemplate(&ctxWriter{rc: rc, w: buf}, name, binding)
+		return buf.Len(), err
+	})
+}
+
+// partial renders the template with the given name on its own, for the
+// partial helper.
+func (r *Render) partial(rc *renderContext, name string, binding interface{}) (template.HTML, error) {
+	buf, err := r.execute(rc, RenderPartial, name, binding)
+	return template.HTML(buf.String()), err
+}
+
+// addRequestFuncs binds the helpers that need to know about the request to
+// the templates of the given render.
+func (r *Render) addRequestFuncs(w http.ResponseWriter, req *http.Request, rc *renderContext, name string, binding interface{}, isPartial bool) {
+	for _, funcs := range r.requestFuncs(w, req, rc, name, binding, isPartial) {
+		rc.templates.Funcs(funcs)
+	}
 }
 
-func (r *Render) addLayoutFuncs(w http.ResponseWriter, req *http.Request, name string, binding interface{}) {
+// requestFuncs returns the helpers that need to know about the request, in
+// the order they are bound.
+func (r *Render) requestFuncs(w http.ResponseWriter, req *http.Request, rc *renderContext, name string, binding interface{}, isPartial bool) []template.FuncMap {
+	// Add the app's helpers first, so that they can't replace ours, as
+	// with Options.Funcs.
+	var maps []template.FuncMap
+	for _, fn := range r.opt.RequestFuncs {
+		maps = append(maps, fn(w, req))
+	}
+
 	funcs := template.FuncMap{
 		"yield": func() (template.HTML, error) {
-			buf, err := r.execute(name, binding)
+			buf, err := r.execute(rc, RenderYield, name, binding)
 			return template.HTML(buf.String()), err
 		},
 
+		// partial renders another template in place, as part of this
+		// render.
+		"partial": func(name string, binding interface{}) (template.HTML, error) {
+			return r.partial(rc, name, binding)
+		},
+
+		// cache renders a partial through the fragment cache, keyed by
+		// the rest of its arguments.
+		"cache": func(name string, binding interface{}, keys ...interface{}) (template.HTML, error) {
+			return r.cached(rc, name, keys, func() (*bytes.Buffer, error) {
+				return r.execute(rc, RenderPartial, name, binding)
+			})
+		},
+
 		// currentpage returns the current URL path.
 		"currentpage": func(page string) bool {
 			return page == req.URL.Path
 		},
 
-		// gitsha returns the SHA of the last git commit.
-		"gitsha": func() string {
-			return r.m.gitSHA
-		},
+		// gitsha returns the SHA of the last git commit. It is kept around
+		// as an alias of revision.
+		"gitsha": r.Revision,
+
+		// revision returns the VCS revision of the running build.
+		"revision": r.Revision,
+
+		// dirty reports whether the running build had uncommitted changes.
+		"dirty": r.Dirty,
+
+		// buildtime returns the time the running binary was built.
+		"buildtime": r.BuildTime,
 
 		// flash gets the flash message.
 		"flash": func() string {
 			return r.GetFlash(w, req)
 		},
+
+		// frontmatter returns the front matter of the view, so that the
+		// layout can use its title.
+		"frontmatter": func() map[string]interface{} {
+			return rc.frontMatter(name)
+		},
+
+		// ctx returns a value set on the request with WithViewValue.
+		"ctx": func(key string) interface{} {
+			return ViewValue(req, key)
+		},
+
+		// preload adds a Link header for a critical asset, as long as the
+		// headers haven't been sent yet.
+		"preload": func(url string, as ...string) string {
+			p := Preload{URL: url}
+			for _, a := range as {
+				p.As = a
+			}
+			addPreloadHeaders(w.Header(), p)
+			return ""
+		},
 	}
 
-	if tpl := r.templates.Lookup(name); tpl != nil {
-		tpl.Funcs(funcs)
+	if r.opt.I18n != nil {
+		var vary []string
+		rc.locale, vary = r.opt.I18n.detect(req)
+		addVary(w.Header(), vary...)
+		for k, v := range r.opt.I18n.Funcs(rc.locale) {
+			funcs[k] = v
+		}
 	}
+
+	// Partials aren't rendered in a layout, so there is nothing to yield
+	// to.
+	if r.layout(rc, name) == "" || isPartial {
+		funcs["yield"] = helperFuncs["yield"]
+	}
+
+	return append(maps, funcs)
 }
 
 // Flash sets a flash message on the given response.
 func (r *Render) Flash(w http.ResponseWriter, message string) {
-	cookie := &http.Cookie{
-		Name:  DefaultFlashCookieName,
-		Value: base64.URLEncoding.EncodeToString([]byte(message)),
-	}
-	http.SetCookie(w, cookie)
+	r.setFlash(w, &FlashMessage{Message: message})
 }
 
-// GetFlash retrieves the flash message the given request.
+// GetFlash retrieves the flash message the given request. Flashes set with
+// FlashKey are translated in the locale of the request.
 func (r *Render) GetFlash(w http.ResponseWriter, req *http.Request) string {
 	cookie, err := req.Cookie(DefaultFlashCookieName)
 	if err != nil {
 		return ""
 	}
 
-	message, err := base64.URLEncoding.DecodeString(cookie.Value)
-	if err != nil {
+	flash, ok := DecodeFlash(cookie.Value)
+	if !ok {
 		return ""
 	}
 
@@ -234,18 +505,18 @@ func (r *Render) GetFlash(w http.ResponseWriter, req *http.Request) string {
 	cookie.Expires = time.Unix(1, 0)
 	http.SetCookie(w, cookie)
 
-	return string(message)
+	return r.translateFlash(req, flash)
 }
 
 // TemplateLookup is a wrapper around template.Lookup and returns
 // the template with the given name that is associated with t, or nil
 // if there is no such template.
 func (r *Render) TemplateLookup(t string) *template.Template {
-	return r.templates.Lookup(t)
+	return r.set("").templates.Lookup(t)
 }
 
 func (r *Render) prepareRender() {
-	if r.opt.Directory == "" {
+	if r.opt.Directory == "" && len(r.opt.Roots) == 0 {
 		wd, err := os.Getwd()
 		if err != nil {
 			panic(err)
@@ -259,84 +530,131 @@ func (r *Render) prepareRender() {
 }
 
 // compileTemplatesFromDir compiles all of the templates under the given
-// directory.
+// directory, once for the base templates and once for each theme.
 //
 // This is (mostly) a copy of
 // https://github.com/unrolled/render/blob/v1/render.go#L185, since they do it
 // the best.
 func (r *Render) compileTemplatesFromDir() {
-	r.templates = template.New(r.opt.Directory)
-	r.templates.Delims(DefaultLeftDelim, DefaultRightDelim)
-
-	// Walk the directory and compile any valid template.
-	filepath.Walk(r.opt.Directory, func(path string, info os.FileInfo, err error) error {
-		// If we encounter a directory, return immediately since we can't
-		// compile it.
-		if info == nil || info.IsDir() {
-			return nil
-		}
+	sets := make(map[string]*templateSet)
+	for _, theme := range r.themes() {
+		sets[theme] = r.compileTemplateSet(theme)
+	}
+
+	// Swap the templates along with the pools of their clones, so that
+	// clones of the old templates are never handed out again.
+	r.mu.Lock()
+	r.sets = sets
+	r.mu.Unlock()
+}
+
+// compileTemplateSet compiles the templates of the given theme.
+func (r *Render) compileTemplateSet(theme string) *templateSet {
+	templates := template.New(r.opt.Directory)
+	templates.Delims(r.delims(""))
+	sums := make(map[string]string)
+	meta := make(map[string]map[string]interface{})
 
-		// Get the path relative to our root template directory.
-		rel, err := filepath.Rel(r.opt.Directory, path)
+	// The plain text parts of emails are parsed as text, so that they
+	// aren't escaped as HTML.
+	texts := texttemplate.New(r.opt.Directory)
+
+	r.walkTemplates(theme, func(name, path string, buf []byte) {
+		// Break out if processing fails, as with parsing below.
+		src, err := r.process(name, filepath.Ext(path), buf)
 		if err != nil {
-			return err
+			panic(err)
 		}
-
-		// Determine the file extension.
-		ext := ""
-		if strings.Index(rel, ".") != -1 {
-			ext = filepath.Ext(rel)
+		if src.FrontMatter != nil {
+			meta[name] = src.FrontMatter
 		}
 
-		// Compile each template. We check if the extension matches the
-		// allowed ones that we defined before compiling.
-		for _, extension := range r.opt.Extensions {
-			if ext == extension {
-				buf, err := ioutil.ReadFile(path)
-				if err != nil {
-					panic(err)
-				}
-
-				name := (rel[0 : len(rel)-len(ext)])
-				tmpl := r.templates.New(filepath.ToSlash(name))
-
-				// Add our funcmaps.
-				for _, funcs := range r.opt.Funcs {
-					tmpl.Funcs(funcs)
-				}
-
-				// Break out if this parsing fails. We don't want any silent
-				// server starts.
-				template.Must(tmpl.Funcs(helperFuncs).Parse(string(buf)))
-				break
+		if isEmailText(name) {
+			tmpl := texts.New(name).Delims(src.LeftDelim, src.RightDelim)
+			for _, funcs := range r.parseFuncs() {
+				tmpl.Funcs(funcs)
 			}
+			texttemplate.Must(tmpl.Parse(string(src.Body)))
+			sums[name] = templateSum(src.Body)
+			return
 		}
 
-		return nil
+		buf = r.minifySource(src.Body, name, src.LeftDelim, src.RightDelim)
+		sums[name] = templateSum(buf)
+		tmpl := templates.New(name).Delims(src.LeftDelim, src.RightDelim)
+		r.addParseFuncs(tmpl)
+
+		// Break out if this parsing fails. We don't want any silent
+		// server starts.
+		template.Must(tmpl.Parse(string(buf)))
 	})
-}
 
-func (r *Render) gatherMeta() {
-	m := &meta{}
+	return &templateSet{
+		templates: templates,
+		clones:    &sync.Pool{},
+		texts:     texts,
+		sums:      includeSums(sums, templates, texts),
+		meta:      meta,
+	}
+}
 
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	buf := &bytes.Buffer{}
-	cmd.Stdout = buf
-	cmd.Stderr = os.Stderr
+// addParseFuncs adds every func that a template can call to the given
+// template, so that it can be parsed.
+func (r *Render) addParseFuncs(tmpl *template.Template) {
+	for _, funcs := range r.parseFuncs() {
+		tmpl.Funcs(funcs)
+	}
+}
 
-	cmd.Run()
-	m.gitSHA = buf.String()
+// parseFuncs returns every func that a template can call, in the order they
+// are added, so that ours always win.
+func (r *Render) parseFuncs() []template.FuncMap {
+	// Add our funcmaps.
+	funcs := append([]template.FuncMap(nil), r.opt.Funcs...)
+	if r.opt.Assets != nil {
+		funcs = append(funcs, r.opt.Assets.Funcs())
+	}
+	for _, fn := range r.opt.RequestFuncs {
+		funcs = append(funcs, fn(placeholderWriter{}, placeholderRequest()))
+	}
+	if r.opt.I18n != nil {
+		funcs = append(funcs, r.opt.I18n.Funcs(r.opt.I18n.Default))
+	}
+	return append(funcs, helperFuncs)
+}
 
-	r.m = m
+// reloadAssets hashes the assets again, if there are any. Errors are
+// ignored, since the asset helpers already fail for missing assets.
+func (r *Render) reloadAssets() {
+	if r.opt.Assets != nil {
+		r.opt.Assets.Reload()
+	}
 }
 
 // Handler is a middleware wrapper for Turbolinks.
 func Handler(h http.Handler) http.Handler {
+	return HandlerWithOptions(h, HandlerOptions{})
+}
+
+// HandlerWithOptions is Handler, configured with the given options.
+func HandlerWithOptions(h http.Handler, opts HandlerOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		referer := r.Header.Get(TurbolinksReferrer)
 		if referer == "" {
-			// Turbolinks isn't enabled, so don't do anything extra.
-			h.ServeHTTP(w, r)
+			if opts.Compression == nil {
+				// Turbolinks isn't enabled, so don't do anything extra.
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// Buffer the response anyway, so that it can be compressed.
+			rs := &responseStaller{
+				w:    w,
+				code: 0,
+				buf:  &bytes.Buffer{},
+			}
+			h.ServeHTTP(rs, r)
+			rs.sendResponse(opts, r)
 			return
 		}
 
@@ -352,7 +670,7 @@ func Handler(h http.Handler) http.Handler {
 
 			// TODO(ben) This opens you up to JavaScript injection via the
 			// value of `location`!!
-			if location := rs.Header().Get("Location"); location != "" {
+			if location := rs.Header().Get("Location"); location != "" && !rs.streaming {
 				rs.Header().Set("Content-Type", "text/javascript")
 				rs.Header().Set("X-Content-Type-Options", "nosniff")
 				rs.WriteHeader(http.StatusOK)
@@ -380,7 +698,7 @@ func Handler(h http.Handler) http.Handler {
 				rs.Write(js)
 			}
 
-			rs.SendResponse()
+			rs.sendResponse(opts, r)
 			return
 		}
 
@@ -422,7 +740,7 @@ func Handler(h http.Handler) http.Handler {
 			})
 		}
 
-		rs.SendResponse()
+		rs.sendResponse(opts, r)
 	})
 }
 
@@ -430,18 +748,72 @@ type responseStaller struct {
 	w    http.ResponseWriter
 	code int
 	buf  *bytes.Buffer
+
+	// streaming is set once the handler flushes, or responds with an
+	// event stream. From then on, writes go straight to the underlying
+	// response writer, without being buffered or compressed.
+	streaming bool
 }
 
 // Write is a wrapper that calls the underlying response writer's Write
 // method, but write the response to a buffer instead.
 func (rw *responseStaller) Write(b []byte) (int, error) {
+	if !rw.streaming && isEventStream(rw.Header()) {
+		rw.stream()
+	}
+	if rw.streaming {
+		return rw.w.Write(b)
+	}
 	return rw.buf.Write(b)
 }
 
 // WriteHeader saves the status code, to be sent later during the SendReponse
-// call.
+// call. Informational responses, like 103 Early Hints, aren't final, so they
+// are passed through to the underlying response writer straight away.
 func (rw *responseStaller) WriteHeader(code int) {
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		rw.w.WriteHeader(code)
+		return
+	}
+	if rw.streaming {
+		return
+	}
 	rw.code = code
+	if isEventStream(rw.Header()) {
+		rw.stream()
+	}
+}
+
+// Flush sends the headers and anything that has been buffered so far, and
+// flushes the underlying response writer if it supports it. The rest of the
+// response isn't buffered.
+func (rw *responseStaller) Flush() {
+	if !rw.streaming {
+		rw.stream()
+	}
+
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream sends the headers and the buffered response, and stops buffering.
+func (rw *responseStaller) stream() {
+	rw.streaming = true
+	rw.SendResponse()
+}
+
+// isEventStream reports whether the response is a stream of server-sent
+// events, which must never be buffered.
+func isEventStream(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// Unwrap returns the underlying response writer, for
+// http.ResponseController.
+func (rw *responseStaller) Unwrap() http.ResponseWriter {
+	return rw.w
 }
 
 // Header wraps the underlying response writers Header method.
@@ -452,10 +824,31 @@ func (rw *responseStaller) Header() http.Header {
 // SendResponse writes the header to the underlying response writer, and
 // writes the response.
 func (rw *responseStaller) SendResponse() {
+	// Handlers that only call Write get an implicit 200, as they would
+	// from net/http.
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+	}
 	rw.w.WriteHeader(rw.code)
 	rw.buf.WriteTo(rw.w)
 }
 
+// sendResponse compresses the response if the options ask for it, and then
+// sends it.
+func (rw *responseStaller) sendResponse(opts HandlerOptions, r *http.Request) {
+	// A streamed response has already been sent.
+	if rw.streaming {
+		return
+	}
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+	}
+	if opts.Compression != nil {
+		opts.Compression.compress(rw, r)
+	}
+	rw.SendResponse()
+}
+
 // IsTLS is a helper to check if a requets was performed over HTTPS.
 func IsTLS(r *http.Request) bool {
 	if r.TLS != nil {
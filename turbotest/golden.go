@@ -0,0 +1,83 @@
+package turbotest
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+// UpdateGolden makes AssertGolden write the golden files from the current
+// output, instead of comparing against them. It's set by the
+// TURBO_UPDATE_GOLDEN environment variable, or by the -update flag once
+// RegisterUpdateFlag has been called.
+var UpdateGolden = os.Getenv("TURBO_UPDATE_GOLDEN") != ""
+
+// RegisterUpdateFlag defines the -update flag, which sets UpdateGolden. It
+// isn't defined on import, since the tests of an app may have an update flag
+// of their own. Call it from TestMain, before flag.Parse, ie,
+//
+//	func TestMain(m *testing.M) {
+//		turbotest.RegisterUpdateFlag()
+//		flag.Parse()
+//		os.Exit(m.Run())
+//	}
+//
+// It does nothing if an update flag is already defined.
+func RegisterUpdateFlag() {
+	if flag.Lookup("update") != nil {
+		return
+	}
+	flag.BoolVar(&UpdateGolden, "update", UpdateGolden, "write the golden files from the current output")
+}
+
+// AssertGolden renders the named template with the given binding, and fails
+// the test if the output doesn't match the contents of
+// testdata/<golden>.golden. Runs of whitespace are collapsed before
+// comparing, so that reindenting a template doesn't break its golden file.
+//
+// Run the tests with TURBO_UPDATE_GOLDEN=1, or with -update after calling
+// RegisterUpdateFlag, to write the golden files from the current output
+// instead.
+func AssertGolden(t testing.TB, render *turbo.Render, golden, name string, binding interface{}, partial ...bool) {
+	t.Helper()
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	actual, err := render.String(res, req, name, binding, partial...)
+	if err != nil {
+		t.Fatalf("unexpected error rendering template %s: %v", name, err)
+	}
+
+	path := filepath.Join("testdata", golden+".golden")
+	if UpdateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run the tests with TURBO_UPDATE_GOLDEN=1 to create it: %v", err)
+	}
+
+	if NormalizeWhitespace(actual) != NormalizeWhitespace(string(expected)) {
+		t.Fatalf("template %s does not match %s\n\nexpected:\n%s\n\ngot:\n%s", name, path, expected, actual)
+	}
+}
+
+// NormalizeWhitespace collapses runs of whitespace into a single space, and
+// removes comments and the whitespace at either end of the given HTML. The
+// contents of pre, textarea, script and style elements, and of attribute
+// values, are left alone, as turbo.Minify leaves them.
+func NormalizeWhitespace(html string) string {
+	return turbo.MinifyHTML(html)
+}
@@ -0,0 +1,80 @@
+package turbotest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bentranter/turbo"
+)
+
+// SpanRecorder is a turbo.Tracer that keeps every span in memory, so that
+// tests can check what was traced.
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan is a span recorded by a SpanRecorder.
+type RecordedSpan struct {
+	// ID is the position of the span in the order spans were started,
+	// starting at one.
+	ID int
+
+	// Parent is the ID of the span this one was started under, or zero if
+	// it has no parent.
+	Parent int
+
+	// Event is the render event the span was ended with, or the one it was
+	// started with if it hasn't ended.
+	Event turbo.RenderEvent
+
+	// Ended reports whether the span has ended.
+	Ended bool
+}
+
+type spanKey struct{}
+
+// Start starts a span.
+func (r *SpanRecorder) Start(ctx context.Context, ev turbo.RenderEvent) (context.Context, turbo.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	span := &RecordedSpan{ID: len(r.spans) + 1, Event: ev}
+	if parent, ok := ctx.Value(spanKey{}).(*RecordedSpan); ok {
+		span.Parent = parent.ID
+	}
+	r.spans = append(r.spans, span)
+
+	return context.WithValue(ctx, spanKey{}, span), &recordedSpan{recorder: r, span: span}
+}
+
+// Spans returns a copy of every span that was started, in order.
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make([]RecordedSpan, len(r.spans))
+	for i, span := range r.spans {
+		spans[i] = *span
+	}
+	return spans
+}
+
+// Reset forgets every span that was recorded.
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	r.spans = nil
+	r.mu.Unlock()
+}
+
+type recordedSpan struct {
+	recorder *SpanRecorder
+	span     *RecordedSpan
+}
+
+func (s *recordedSpan) End(ev turbo.RenderEvent) {
+	s.recorder.mu.Lock()
+	s.span.Event = ev
+	s.span.Ended = true
+	s.recorder.mu.Unlock()
+}
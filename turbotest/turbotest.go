@@ -0,0 +1,265 @@
+// Package turbotest provides utilities for testing handlers wrapped in
+// turbo.Handler, without hand-rolling the requests that the Turbolinks
+// frontend would make.
+package turbotest
+
+import (
+	"html"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+// maxRedirects is the number of redirects a Client follows before giving
+// up, the same as net/http.
+const maxRedirects = 10
+
+// TurboStreamContentType is the Content-Type of Turbo Stream responses.
+const TurboStreamContentType = "text/vnd.turbo-stream.html"
+
+// visitRe matches the JavaScript that turbo.Handler responds with after a
+// form submission that redirects.
+var visitRe = regexp.MustCompile(`Turbolinks\.visit\(("(?:[^"\\]|\\.)*"), \{action: "(\w+)"\}\);`)
+
+// streamRe and streamAttrRe match the start tags of turbo-stream elements
+// and their attributes.
+var (
+	streamRe     = regexp.MustCompile(`(?i)<turbo-stream(\s[^>]*)?>`)
+	streamAttrRe = regexp.MustCompile(`([A-Za-z_:][-A-Za-z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+)
+
+// Client is a fake Turbolinks client. It sends the requests that Turbolinks
+// would, keeps the cookies that are set on it, and follows redirects and
+// visits like the browser would.
+type Client struct {
+	// Handler is the handler that requests are sent to. It should usually
+	// be wrapped in turbo.Handler.
+	Handler http.Handler
+
+	// Jar holds the cookies that were set by the handler.
+	Jar http.CookieJar
+
+	// Location is the URL of the current page, and is sent as the
+	// Turbolinks-Referrer header.
+	Location string
+}
+
+// NewClient returns a client that sends requests to the given handler.
+func NewClient(h http.Handler) *Client {
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		Handler:  h,
+		Jar:      jar,
+		Location: "http://example.com/",
+	}
+}
+
+// Visit performs a Turbolinks visit to the given path, following any
+// redirects.
+func (c *Client) Visit(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, c.resolve(path).String(), nil)
+	return c.Do(req)
+}
+
+// Submit submits a form to the given path. If the handler responds with a
+// Turbolinks visit, the visit is followed, and the response of the visit is
+// returned.
+func (c *Client) Submit(path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, c.resolve(path).String(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res := c.Do(req)
+	if location, _, ok := ParseVisit(res); ok {
+		return c.Visit(location)
+	}
+	return res
+}
+
+// Do sends the given request to the handler as Turbolinks would, and
+// follows any redirects.
+func (c *Client) Do(req *http.Request) *httptest.ResponseRecorder {
+	for i := 0; ; i++ {
+		req.Header.Set(turbo.TurbolinksReferrer, c.Location)
+		for _, cookie := range c.Jar.Cookies(req.URL) {
+			req.AddCookie(cookie)
+		}
+
+		res := httptest.NewRecorder()
+		c.Handler.ServeHTTP(res, req)
+		c.Jar.SetCookies(req.URL, res.Result().Cookies())
+
+		location := res.Header().Get("Location")
+		if location == "" || res.Code < 300 || res.Code > 399 || i == maxRedirects {
+			if res.Code == http.StatusOK && req.Method == http.MethodGet {
+				c.Location = req.URL.String()
+			}
+			return res
+		}
+
+		// Like the browser, follow the redirect with a GET request.
+		req = httptest.NewRequest(http.MethodGet, req.URL.ResolveReference(mustParse(location)).String(), nil)
+	}
+}
+
+// resolve resolves the given path against the current location.
+func (c *Client) resolve(path string) *url.URL {
+	return mustParse(c.Location).ResolveReference(mustParse(path))
+}
+
+func mustParse(rawurl string) *url.URL {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// ParseVisit parses the Turbolinks visit that turbo.Handler responds with
+// after a form submission.
+func ParseVisit(res *httptest.ResponseRecorder) (location, action string, ok bool) {
+	if !strings.HasPrefix(res.Header().Get("Content-Type"), "text/javascript") {
+		return "", "", false
+	}
+
+	m := visitRe.FindStringSubmatch(res.Body.String())
+	if m == nil {
+		return "", "", false
+	}
+
+	// The location is escaped with template.JSEscapeString, which escapes
+	// single quotes as well. Go doesn't allow those in a double quoted
+	// string.
+	location, err := strconv.Unquote(strings.Replace(m[1], `\'`, `'`, -1))
+	if err != nil {
+		return "", "", false
+	}
+	return location, m[2], true
+}
+
+// TurboStream is a turbo-stream element of a Turbo Stream response.
+type TurboStream struct {
+	Action string
+	Target string
+}
+
+// ParseTurboStreams parses the turbo-stream elements of the response. It
+// returns false if the response isn't a Turbo Stream response.
+func ParseTurboStreams(res *httptest.ResponseRecorder) ([]TurboStream, bool) {
+	mediaType, _, err := mime.ParseMediaType(res.Header().Get("Content-Type"))
+	if err != nil || mediaType != TurboStreamContentType {
+		return nil, false
+	}
+
+	var streams []TurboStream
+	for _, m := range streamRe.FindAllStringSubmatch(res.Body.String(), -1) {
+		var stream TurboStream
+		for _, attr := range streamAttrRe.FindAllStringSubmatch(m[1], -1) {
+			value := html.UnescapeString(attr[2] + attr[3] + attr[4])
+			switch strings.ToLower(attr[1]) {
+			case "action":
+				stream.Action = value
+			case "target":
+				stream.Target = value
+			}
+		}
+		streams = append(streams, stream)
+	}
+	return streams, true
+}
+
+// Flash returns the flash message set on the response, if there is one. For
+// flashes set with FlashKey, the untranslated key is returned.
+func Flash(res *httptest.ResponseRecorder) (string, bool) {
+	flash, ok := FlashMessage(res)
+	if !ok {
+		return "", false
+	}
+	return flash.String(), true
+}
+
+// FlashMessage returns the decoded flash set on the response, if there is
+// one.
+func FlashMessage(res *httptest.ResponseRecorder) (*turbo.FlashMessage, bool) {
+	for _, cookie := range res.Result().Cookies() {
+		if cookie.Name != turbo.DefaultFlashCookieName || cookie.MaxAge < 0 {
+			continue
+		}
+		return turbo.DecodeFlash(cookie.Value)
+	}
+	return nil, false
+}
+
+// AssertVisit fails the test if the response isn't a Turbolinks visit to the
+// given location with the given action.
+func AssertVisit(t testing.TB, res *httptest.ResponseRecorder, location, action string) {
+	t.Helper()
+
+	actualLocation, actualAction, ok := ParseVisit(res)
+	if !ok {
+		t.Fatalf("expected Turbolinks visit to %s but got response %d: %s", location, res.Code, res.Body.String())
+	}
+	if actualLocation != location {
+		t.Fatalf("expected Turbolinks visit to %s but got %s", location, actualLocation)
+	}
+	if actualAction != action {
+		t.Fatalf("expected Turbolinks visit with action %s but got %s", action, actualAction)
+	}
+}
+
+// AssertLocation fails the test if the response doesn't tell Turbolinks to
+// update the URL to the given location, as it must after a redirect.
+func AssertLocation(t testing.TB, res *httptest.ResponseRecorder, location string) {
+	t.Helper()
+
+	if actual := res.Header().Get("Turbolinks-Location"); actual != location {
+		t.Fatalf("expected Turbolinks-Location %s but got %q", location, actual)
+	}
+}
+
+// AssertTurboStream fails the test if the response isn't a Turbo Stream
+// response with a turbo-stream element with the given action and target.
+func AssertTurboStream(t testing.TB, res *httptest.ResponseRecorder, action, target string) {
+	t.Helper()
+
+	streams, ok := ParseTurboStreams(res)
+	if !ok {
+		t.Fatalf("expected Turbo Stream response but got Content-Type %q", res.Header().Get("Content-Type"))
+	}
+	for _, s := range streams {
+		if s.Action == action && s.Target == target {
+			return
+		}
+	}
+	t.Fatalf("expected turbo-stream with action %s and target %s but got %v", action, target, streams)
+}
+
+// AssertFlash fails the test if the response doesn't set the given flash
+// message.
+func AssertFlash(t testing.TB, res *httptest.ResponseRecorder, message string) {
+	t.Helper()
+
+	actual, ok := Flash(res)
+	if !ok {
+		t.Fatalf("expected flash message %q but none was set", message)
+	}
+	if actual != message {
+		t.Fatalf("expected flash message %q but got %q", message, actual)
+	}
+}
+
+// AssertStatus fails the test if the response doesn't have the given status.
+func AssertStatus(t testing.TB, res *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if res.Code != status {
+		t.Fatalf("expected HTTP status %d but got %d: %s", status, res.Code, res.Body.String())
+	}
+}
@@ -0,0 +1,146 @@
+package turbotest_test
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bentranter/turbo"
+	"github.com/bentranter/turbo/turbotest"
+)
+
+func TestClient(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "../fixtures/basic",
+		Layout:    "layout",
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		render.HTML(w, r, http.StatusOK, "content", render.GetFlash(w, r))
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		render.HTML(w, r, http.StatusOK, "content", "new")
+	})
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", turbotest.TurboStreamContentType+"; charset=utf-8")
+		w.Write([]byte(`<turbo-stream action="append" target="messages"><template><p>hi</p></template></turbo-stream>` +
+			`<turbo-stream target='message_1' action=remove></turbo-stream>`))
+	})
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		render.Redirect(w, r, "/users/1?name="+url.QueryEscape(r.FormValue("name")), "User created")
+	})
+
+	t.Run("visit follows redirects", func(t *testing.T) {
+		c := turbotest.NewClient(turbo.Handler(mux))
+
+		res := c.Visit("/old")
+		turbotest.AssertStatus(t, res, http.StatusOK)
+		turbotest.AssertLocation(t, res, "/new")
+	})
+
+	t.Run("turbo streams", func(t *testing.T) {
+		c := turbotest.NewClient(turbo.Handler(mux))
+
+		res := c.Visit("/messages")
+		turbotest.AssertStatus(t, res, http.StatusOK)
+		turbotest.AssertTurboStream(t, res, "append", "messages")
+		turbotest.AssertTurboStream(t, res, "remove", "message_1")
+
+		if _, ok := turbotest.ParseTurboStreams(c.Visit("/new")); ok {
+			t.Fatalf("expected HTML response not to be parsed as a Turbo Stream")
+		}
+	})
+
+	t.Run("form submission responds with a visit", func(t *testing.T) {
+		c := turbotest.NewClient(turbo.Handler(mux))
+
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com/users", nil)
+		req.PostForm = url.Values{"name": {`"quoted"`}}
+		res := c.Do(req)
+
+		turbotest.AssertStatus(t, res, http.StatusOK)
+		turbotest.AssertVisit(t, res, `/users/1?name=%22quoted%22`, "advance")
+		turbotest.AssertFlash(t, res, "User created")
+	})
+
+	t.Run("flash keys are returned untranslated", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		render.RedirectKey(res, httptest.NewRequest(http.MethodPost, "/users", nil), "/users/1", "users.created", "name", "ben")
+
+		turbotest.AssertFlash(t, res, "users.created")
+		if flash, _ := turbotest.FlashMessage(res); len(flash.Args) != 2 || flash.Args[1] != "ben" {
+			t.Fatalf("expected flash args to be kept but got %v", flash.Args)
+		}
+	})
+
+	t.Run("submit follows the visit and keeps cookies", func(t *testing.T) {
+		c := turbotest.NewClient(turbo.Handler(mux))
+
+		res := c.Submit("/users", url.Values{"name": {"ben"}})
+		turbotest.AssertStatus(t, res, http.StatusOK)
+		if body := res.Body.String(); body != "head<p>User created</p>foot" {
+			t.Fatalf("expected flash to be rendered but got %s", body)
+		}
+		if c.Location != "http://example.com/users/1?name=ben" {
+			t.Fatalf("expected client location to be updated but got %s", c.Location)
+		}
+	})
+}
+
+func TestAssertGolden(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "../fixtures/basic",
+		Layout:    "layout",
+	})
+
+	turbotest.AssertGolden(t, render, "content", "content", "test")
+
+	// The tests of an app can define an update flag of their own.
+	if flag.Lookup("update") != nil {
+		t.Fatalf("expected turbotest not to define the update flag")
+	}
+
+	t.Run("update flag", func(t *testing.T) {
+		defer func(fs *flag.FlagSet) { flag.CommandLine = fs }(flag.CommandLine)
+		flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+
+		turbotest.RegisterUpdateFlag()
+		turbotest.RegisterUpdateFlag()
+		defer func(v bool) { turbotest.UpdateGolden = v }(turbotest.UpdateGolden)
+
+		if err := flag.Set("update", "true"); err != nil {
+			t.Fatalf("expected the update flag to be defined but got %v", err)
+		}
+		if !turbotest.UpdateGolden {
+			t.Fatalf("expected the update flag to set UpdateGolden")
+		}
+	})
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{"<p>test</p>", "<p>test</p>"},
+		{"\n  <p>\n\ttest  </p>\n", "<p> test </p>"},
+		{"<ul>\n  <li>a</li>\n  <li>b</li>\n</ul>", "<ul> <li>a</li> <li>b</li> </ul>"},
+		{"a  b\n\nc", "a b c"},
+		{"head\n  <p class=\"a  b\">x</p>", "head <p class=\"a  b\">x</p>"},
+		{"<a>x</a> <b>y</b>", "<a>x</a> <b>y</b>"},
+		{"<pre>\n  a\n    b\n</pre>", "<pre>\n  a\n    b\n</pre>"},
+		{"<textarea>  a  </textarea>\n<p>b</p>", "<textarea>  a  </textarea> <p>b</p>"},
+	}
+
+	for _, tt := range tests {
+		if actual := turbotest.NormalizeWhitespace(tt.html); actual != tt.expected {
+			t.Errorf("expected %q to normalize to %q but got %q", tt.html, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package turbo
+
+import (
+	"context"
+	"net/http"
+)
+
+type viewContextKey struct{}
+
+// WithViewValue returns a copy of the request that carries the given value
+// for the templates rendered for it. Templates read it with the ctx helper,
+// so that middleware can provide the values that every layout needs, like
+// the current user, without each handler adding them to its binding:
+//
+//	func auth(h http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			h.ServeHTTP(w, turbo.WithViewValue(r, "current_user", currentUser(r)))
+//		})
+//	}
+//
+//	<p>Signed in as {{ (ctx "current_user").Name }}</p>
+func WithViewValue(r *http.Request, key string, value interface{}) *http.Request {
+	old := viewValues(r)
+
+	// Copy the values so that requests derived from the same parent don't
+	// see each other's values.
+	values := make(map[string]interface{}, len(old)+1)
+	for k, v := range old {
+		values[k] = v
+	}
+	values[key] = value
+
+	return r.WithContext(context.WithValue(r.Context(), viewContextKey{}, values))
+}
+
+// ViewValue returns the value set for the given key with WithViewValue, or
+// nil if there isn't one.
+func ViewValue(r *http.Request, key string) interface{} {
+	return viewValues(r)[key]
+}
+
+func viewValues(r *http.Request) map[string]interface{} {
+	values, _ := r.Context().Value(viewContextKey{}).(map[string]interface{})
+	return values
+}
@@ -0,0 +1,71 @@
+package turbo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bentranter/turbo"
+)
+
+func TestRender_ViewValues(t *testing.T) {
+	render := turbo.New(turbo.Options{
+		Directory: "fixtures/view",
+		Layout:    "layout",
+	})
+
+	type user struct {
+		Name string
+	}
+
+	t.Run("layout reads values set by middleware", func(t *testing.T) {
+		const expected = `ben|Home|<p>test</p>`
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := render.HTML(w, r, http.StatusOK, "content", "test"); err != nil {
+				t.Fatalf("unexpected error rendering template: %v", err)
+			}
+		})
+		mw := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r = turbo.WithViewValue(r, "current_user", &user{Name: "ben"})
+				r = turbo.WithViewValue(r, "title", "Home")
+				h.ServeHTTP(w, r)
+			})
+		}
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		mw(h).ServeHTTP(res, req)
+
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+
+	t.Run("missing values render as empty", func(t *testing.T) {
+		const expected = `|<p>test</p>`
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := render.HTML(res, req, http.StatusOK, "content", "test"); err != nil {
+			t.Fatalf("unexpected error rendering template: %v", err)
+		}
+		if body := res.Body.String(); body != expected {
+			t.Fatalf("expected %s but got %s", expected, body)
+		}
+	})
+
+	t.Run("derived requests don't share values", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		a := turbo.WithViewValue(req, "title", "A")
+		b := turbo.WithViewValue(req, "title", "B")
+
+		if turbo.ViewValue(a, "title") != "A" || turbo.ViewValue(b, "title") != "B" {
+			t.Fatalf("expected derived requests to keep their own values")
+		}
+		if turbo.ViewValue(req, "title") != nil {
+			t.Fatalf("expected parent request to have no values")
+		}
+	})
+}